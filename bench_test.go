@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/jeffail/gabs"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// largeFixture returns a response shaped like both v2/apps and metrics of a
+// big cluster: apps apps and metrics Dropwizard metrics of each type.
+func largeFixture(apps, metrics int) string {
+	var b bytes.Buffer
+	b.WriteString(`{"version": "3.0.0", "apps": [`)
+	for i := 0; i < apps; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"id": "/group/app-%d", "version": "2018-01-01T00:00:00.000Z", "instances": 3,
+			"tasksRunning": 3, "tasksStaged": 0, "tasksHealthy": 3, "tasksUnhealthy": 0,
+			"cpus": 0.5, "mem": 512, "disk": 0, "gpus": 0,
+			"taskStats": {"startedAfterLastScaling": {"stats": {"lifeTime": {"averageSeconds": 3600}}}}}`, i)
+	}
+	b.WriteString(`]`)
+
+	sections := []struct {
+		name, format string
+	}{
+		{"counters", `{"count": %d}`},
+		{"gauges", `{"value": %d}`},
+		{"meters", `{"count": %d, "m1_rate": 1, "m5_rate": 1, "m15_rate": 1, "mean_rate": 1, "units": "events/second"}`},
+		{"histograms", `{"count": %d, "p50": 1, "p75": 1, "p95": 1, "p98": 1, "p99": 1, "p999": 1, "max": 1, "mean": 1, "min": 1, "stddev": 1}`},
+		{"timers", `{"count": %d, "p50": 1, "p75": 1, "p95": 1, "p98": 1, "p99": 1, "p999": 1, "max": 1, "mean": 1, "min": 1, "stddev": 1, "m1_rate": 1, "m5_rate": 1, "m15_rate": 1, "mean_rate": 1, "duration_units": "seconds", "rate_units": "calls/second"}`},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(&b, `, "%s": {`, section.name)
+		for i := 0; i < metrics; i++ {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, `"mesosphere.marathon.%s.metric%d": `+section.format, section.name, i, i)
+		}
+		b.WriteString(`}`)
+	}
+	b.WriteString(`}`)
+	return b.String()
+}
+
+func Benchmark_collect_large_fixture(b *testing.B) {
+	exporter := NewExporter(&testScraper{largeFixture(500, 300)}, "marathon")
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range ch {
+			m.Write(&dto.Metric{})
+		}
+		close(done)
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		exporter.Collect(ch)
+	}
+	b.StopTimer()
+	close(ch)
+	<-done
+}
+
+func Benchmark_scrape_parsed_large_fixture(b *testing.B) {
+	exporter := NewExporter(&testScraper{`{}`}, "marathon")
+	json, err := gabs.ParseJSON([]byte(largeFixture(500, 300)))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range ch {
+			m.Write(&dto.Metric{})
+		}
+		close(done)
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		exporter.scrapeApps(json, ch)
+		exporter.scrapeMetrics(json, ch)
+	}
+	b.StopTimer()
+	close(ch)
+	<-done
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+var errCircuitOpen = errors.New("circuit breaker is open, not querying Marathon")
+
+// circuitBreaker stops requests to Marathon after a run of consecutive
+// failures. Once the cooldown has elapsed a single trial request is let
+// through: its success closes the circuit again, its failure re-opens it.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mutex    sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	stateGauge prometheus.Gauge
+}
+
+func newCircuitBreaker(namespace string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		stateGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "circuit_breaker_state",
+			Help:      "State of the circuit breaker guarding requests to Marathon (0 for closed, 1 for open, 2 for half-open).",
+		}),
+	}
+}
+
+// allow reports whether a request may be sent to Marathon.
+func (b *circuitBreaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.threshold <= 0 {
+		return true
+	}
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		log.Infoln("Circuit breaker half-open, trying Marathon again")
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of a request that was allowed.
+// Requests canceled by the exporter itself say nothing about Marathon's health.
+func (b *circuitBreaker) record(err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.threshold <= 0 {
+		return
+	}
+	b.probing = false
+	if err == context.Canceled {
+		return
+	}
+	if err == nil {
+		if b.state != breakerClosed {
+			log.Infoln("Circuit breaker closed, Marathon is healthy again")
+		}
+		b.failures = 0
+		b.setState(breakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold) {
+		log.Warnf("Circuit breaker open after %d consecutive failures, pausing requests to Marathon for %v\n", b.failures, b.cooldown)
+		b.openedAt = b.now()
+		b.setState(breakerOpen)
+	}
+}
+
+// configure changes the threshold and cooldown of the breaker, keeping its
+// state.
+func (b *circuitBreaker) configure(threshold int, cooldown time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.threshold, b.cooldown = threshold, cooldown
+}
+
+func (b *circuitBreaker) setState(state int) {
+	b.state = state
+	b.stateGauge.Set(float64(state))
+}
+
+// Describe implements prometheus.Collector.
+func (b *circuitBreaker) Describe(ch chan<- *prometheus.Desc) {
+	b.stateGauge.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (b *circuitBreaker) Collect(ch chan<- prometheus.Metric) {
+	b.stateGauge.Collect(ch)
+}
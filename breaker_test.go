@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_breaker_opens_after_threshold(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker("marathon", 2, time.Minute)
+	b.now = func() time.Time { return now }
+	failure := errors.New("boom")
+
+	for i := 0; i < 2; i++ {
+		if !b.allow() {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+		b.record(failure)
+	}
+	if b.state != breakerOpen {
+		t.Fatalf("expected breaker to be open, got state %d", b.state)
+	}
+	if b.allow() {
+		t.Fatal("expected requests to be rejected while open")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow() {
+		t.Fatal("expected a trial request after the cooldown")
+	}
+	if b.state != breakerHalfOpen {
+		t.Fatalf("expected breaker to be half-open, got state %d", b.state)
+	}
+	if b.allow() {
+		t.Fatal("expected a single trial request while half-open")
+	}
+
+	b.record(nil)
+	if b.state != breakerClosed {
+		t.Fatalf("expected breaker to be closed, got state %d", b.state)
+	}
+	if !b.allow() {
+		t.Fatal("expected requests to be allowed once closed")
+	}
+}
+
+func Test_breaker_reopens_on_failed_trial(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreaker("marathon", 1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.allow()
+	b.record(errors.New("boom"))
+	now = now.Add(time.Minute)
+	b.allow()
+	b.record(errors.New("boom"))
+
+	if b.state != breakerOpen {
+		t.Fatalf("expected breaker to be open, got state %d", b.state)
+	}
+	if b.allow() {
+		t.Fatal("expected requests to be rejected after a failed trial")
+	}
+}
+
+func Test_breaker_disabled(t *testing.T) {
+	b := newCircuitBreaker("marathon", 0, time.Minute)
+	for i := 0; i < 10; i++ {
+		if !b.allow() {
+			t.Fatal("expected a disabled breaker to allow every request")
+		}
+		b.record(errors.New("boom"))
+	}
+}
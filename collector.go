@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultCollectorConcurrency = 2
+	defaultCollectorTimeout     = 10 * time.Second
+)
+
+// collector exports the metrics of a single, independent Marathon endpoint.
+type collector struct {
+	name    string
+	export  func(ctx context.Context, ch chan<- prometheus.Metric) error
+	timeout time.Duration
+}
+
+type collectorResult struct {
+	name     string
+	metrics  []prometheus.Metric
+	duration time.Duration
+	err      error
+}
+
+// collectors returns the enabled collectors, with their own timeout if one
+// is configured.
+func (e *Exporter) collectors() []collector {
+	var enabled []collector
+	for _, c := range []collector{
+		{name: "apps", export: e.exportApps},
+		{name: "metrics", export: e.exportMetrics},
+	} {
+		config := e.collectorConfigs[c.name]
+		if config.disabled() {
+			continue
+		}
+		c.timeout = config.Timeout
+		enabled = append(enabled, c)
+	}
+	return enabled
+}
+
+// collect runs the collectors on a bounded pool of workers, each under its
+// own timeout. Metrics are buffered per collector and forwarded to ch in
+// collector order once every collector has finished, so the output does not
+// depend on which endpoint answered first. Metrics colliding with one
+// forwarded before them, or over the series limits, are dropped.
+func (e *Exporter) collect(collectors []collector, ch chan<- prometheus.Metric) []collectorResult {
+	concurrency := e.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]collectorResult, len(collectors))
+	workers := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, c := range collectors {
+		wg.Add(1)
+		go func(i int, c collector) {
+			defer wg.Done()
+			workers <- struct{}{}
+			defer func() { <-workers }()
+			results[i] = e.run(c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	series := newSeriesSet(e.Descs)
+	for _, result := range results {
+		for _, metric := range result.metrics {
+			e.addSeries(series, metric)
+		}
+	}
+	e.limit(series)
+	e.recordSeries(series.counts())
+	for _, metric := range series.metrics() {
+		ch <- metric
+	}
+	return results
+}
+
+func (e *Exporter) run(c collector) (result collectorResult) {
+	result.name = c.name
+	begin := time.Now()
+	defer func() {
+		result.duration = time.Since(begin)
+	}()
+
+	timeout := e.timeout
+	if c.timeout > 0 {
+		timeout = c.timeout
+	}
+	ctx, cancel := context.WithTimeout(e.ctx, timeout)
+	defer cancel()
+
+	metricCh := make(chan prometheus.Metric)
+	doneCh := make(chan struct{})
+	go func() {
+		for m := range metricCh {
+			result.metrics = append(result.metrics, m)
+		}
+		close(doneCh)
+	}()
+
+	result.err = c.export(ctx, metricCh)
+	close(metricCh)
+	<-doneCh
+	return
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func testCollector(name string, delay time.Duration) collector {
+	return collector{
+		name: name,
+		export: func(ctx context.Context, ch chan<- prometheus.Metric) error {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			ch <- prometheus.MustNewConstMetric(
+				prometheus.NewDesc(name, name, nil, nil),
+				prometheus.GaugeValue, 1)
+			return nil
+		},
+	}
+}
+
+func collectNames(e *Exporter, collectors []collector) ([]string, []collectorResult) {
+	ch := make(chan prometheus.Metric, 16)
+	results := e.collect(collectors, ch)
+	close(ch)
+
+	var names []string
+	for m := range ch {
+		names = append(names, m.Desc().String())
+	}
+	return names, results
+}
+
+func Test_collect_order(t *testing.T) {
+	e := NewExporter(&testScraper{`{}`}, "marathon")
+	e.concurrency = 3
+
+	names, _ := collectNames(e, []collector{
+		testCollector("slow", 30*time.Millisecond),
+		testCollector("medium", 15*time.Millisecond),
+		testCollector("fast", 0),
+	})
+
+	if len(names) != 3 {
+		t.Fatalf("expected 3 metrics, got %d", len(names))
+	}
+	for i, name := range []string{"slow", "medium", "fast"} {
+		if !strings.Contains(names[i], `fqName: "`+name+`"`) {
+			t.Errorf("expected metric %d to come from %s, got %s", i, name, names[i])
+		}
+	}
+}
+
+func Test_collect_concurrency(t *testing.T) {
+	e := NewExporter(&testScraper{`{}`}, "marathon")
+	e.concurrency = 2
+
+	var running, peak int32
+	track := collector{
+		name: "track",
+		export: func(ctx context.Context, ch chan<- prometheus.Metric) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		},
+	}
+
+	collectNames(e, []collector{track, track, track, track, track})
+	if peak > 2 {
+		t.Errorf("expected at most 2 concurrent collectors, got %d", peak)
+	}
+}
+
+func Test_collect_timeout(t *testing.T) {
+	e := NewExporter(&testScraper{`{}`}, "marathon")
+	e.timeout = 10 * time.Millisecond
+
+	names, results := collectNames(e, []collector{
+		testCollector("stuck", time.Minute),
+		testCollector("fast", 0),
+	})
+
+	if err := results[0].err; err != context.DeadlineExceeded {
+		t.Errorf("expected the stuck collector to time out, got %v", err)
+	}
+	if results[1].err != nil {
+		t.Errorf("expected the fast collector to succeed, got %v", results[1].err)
+	}
+	if len(names) != 1 {
+		t.Errorf("expected only the fast collector's metric, got %v", names)
+	}
+}
+
+func Test_collect_cancelled(t *testing.T) {
+	e := NewExporter(&testScraper{`{}`}, "marathon")
+	ctx, cancel := context.WithCancel(context.Background())
+	e.ctx = ctx
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	begin := time.Now()
+	_, results := collectNames(e, []collector{testCollector("stopped", time.Minute)})
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("expected the collector to stop with the exporter, took %v", elapsed)
+	}
+	if results[0].err != context.Canceled {
+		t.Errorf("expected the collector to be cancelled, got %v", results[0].err)
+	}
+}
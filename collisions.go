@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/log"
+)
+
+// seriesSet holds the series exported by a scrape, to detect the metrics
+// colliding with them. Once renamed, different Dropwizard metrics can share
+// a name: a.b and a-b both become a_b, and a gauge foo_count clashes with
+// the counter of a meter foo.
+type seriesSet struct {
+	descs    *DescContainer
+	names    map[*prometheus.Desc]string
+	families map[string]*seriesFamily
+	entries  []seriesEntry
+}
+
+// seriesEntry is a metric added to a seriesSet, in the order of addition.
+type seriesEntry struct {
+	metric  prometheus.Metric
+	family  string
+	key     string
+	dropped bool
+}
+
+type seriesFamily struct {
+	desc   *prometheus.Desc
+	typ    dto.MetricType
+	series map[string]bool
+}
+
+func newSeriesSet(descs *DescContainer) *seriesSet {
+	return &seriesSet{
+		descs:    descs,
+		names:    make(map[*prometheus.Desc]string),
+		families: make(map[string]*seriesFamily),
+	}
+}
+
+// add records the series of m, unless it collides with a series added
+// before, in which case the returned error tells why. Invalid metrics are
+// kept without a family, for the handler to report.
+func (s *seriesSet) add(m prometheus.Metric) error {
+	desc := m.Desc()
+	name, ok := s.names[desc]
+	if !ok {
+		// Parsing the descriptor is slow, most metrics come from the container
+		if name, ok = s.descs.Name(desc); !ok {
+			var err error
+			if name, _, err = describe(desc); err != nil {
+				s.entries = append(s.entries, seriesEntry{metric: m})
+				return nil
+			}
+		}
+		s.names[desc] = name
+	}
+
+	metric := &dto.Metric{}
+	if err := m.Write(metric); err != nil {
+		s.entries = append(s.entries, seriesEntry{metric: m})
+		return nil
+	}
+	typ := *metricType(metric)
+
+	family, ok := s.families[name]
+	if !ok {
+		family = &seriesFamily{desc: desc, typ: typ, series: make(map[string]bool)}
+		s.families[name] = family
+	}
+	switch {
+	case family.desc != desc:
+		return fmt.Errorf("%s is already exported with other labels", name)
+	case family.typ != typ:
+		return fmt.Errorf("%s is already exported as a %s", name, strings.ToLower(family.typ.String()))
+	}
+
+	// Label names are the same across the family, values identify the series
+	key := make([]byte, 0, 64)
+	for _, pair := range metric.Label {
+		key = append(append(key, pair.GetValue()...), separator)
+	}
+	if family.series[string(key)] {
+		return fmt.Errorf("%s%s is already exported", name, seriesLabels(metric.Label))
+	}
+	family.series[string(key)] = true
+	s.entries = append(s.entries, seriesEntry{metric: m, family: name, key: string(key)})
+	return nil
+}
+
+// counts returns the number of series not dropped per family.
+func (s *seriesSet) counts() map[string]int {
+	counts := make(map[string]int)
+	for _, entry := range s.entries {
+		if !entry.dropped && entry.family != "" {
+			counts[entry.family]++
+		}
+	}
+	return counts
+}
+
+// metrics returns the metrics added and not dropped since, in order.
+func (s *seriesSet) metrics() []prometheus.Metric {
+	metrics := make([]prometheus.Metric, 0, len(s.entries))
+	for _, entry := range s.entries {
+		if !entry.dropped {
+			metrics = append(metrics, entry.metric)
+		}
+	}
+	return metrics
+}
+
+// separator cannot appear in valid UTF-8 label values.
+const separator = '\xff'
+
+func seriesLabels(pairs []*dto.LabelPair) string {
+	labels := make([]string, len(pairs))
+	for i, pair := range pairs {
+		labels[i] = fmt.Sprintf("%s=%q", pair.GetName(), pair.GetValue())
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// addSeries adds m to the series of a scrape, dropping it if it collides
+// with a series added before. Since a scrape exports metrics in the same
+// order every time, the same metric is kept on every scrape.
+func (e *Exporter) addSeries(series *seriesSet, m prometheus.Metric) {
+	if err := series.add(m); err != nil {
+		e.metricCollisions.Inc()
+		e.warnOnce(err.Error(), "Dropped colliding metric: %s\n", err)
+	}
+}
+
+// warnOnce logs a warning the first time key is met, and at debug level
+// afterwards, as the problems of a scrape usually recur on every scrape.
+func (e *Exporter) warnOnce(key string, format string, args ...interface{}) {
+	e.warnedMutex.Lock()
+	warned := e.warned[key]
+	e.warned[key] = true
+	e.warnedMutex.Unlock()
+
+	if warned {
+		log.Debugf(format, args...)
+	} else {
+		log.Warnf(format, args...)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_export_collisions(t *testing.T) {
+	s := pathScraper{
+		"v2/apps?embed=apps.taskStats": `{"apps": []}`,
+		"v2/info":                      `{"version": "1.6.322"}`,
+		"metrics": `{
+			"counters": {
+				"foo.bar": {"count": 1},
+				"foo-bar": {"count": 2}
+			},
+			"gauges": {
+				"baz_count": {"value": 3}
+			},
+			"meters": {
+				"baz": {"count": 4, "m1_rate": 1, "m5_rate": 1, "m15_rate": 1, "mean_rate": 1, "units": "events/second"}
+			}
+		}`,
+	}
+
+	// The same metrics are kept on every scrape
+	for i := 0; i < 3; i++ {
+		results := scrapeWith(t, s, metricsFormatAuto)
+		assertResultsContain(t, results,
+			"marathon_foo_bar 2",
+			"# TYPE marathon_baz_count gauge",
+			"marathon_baz_count 3",
+			`marathon_baz_per_second\{rate="1m"\} 1`,
+			"marathon_exporter_metric_collisions_total 2",
+			"marathon_up 1")
+		assertResultsDoNotContain(t, results,
+			"marathon_foo_bar 1",
+			"marathon_baz_count 4")
+	}
+}
+
+func Test_series_set_labels(t *testing.T) {
+	descs := NewDescContainer("marathon")
+	series := newSeriesSet(descs)
+	desc, _ := descs.Fetch("foo", "help", "app")
+	other, _ := descs.Fetch("foo", "help", "app", "version")
+
+	gauge := func(desc *prometheus.Desc, values ...string) prometheus.Metric {
+		return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+	}
+	metrics := []struct {
+		metric  prometheus.Metric
+		collide bool
+	}{
+		{metric: gauge(desc, "a"), collide: false},
+		{metric: gauge(desc, "b"), collide: false},
+		{metric: gauge(desc, "a"), collide: true},
+		{metric: gauge(other, "c", "1"), collide: true},
+	}
+	for i, m := range metrics {
+		if err := series.add(m.metric); (err != nil) != m.collide {
+			t.Errorf("metric %d: expected collision %v, got %v", i, m.collide, err)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// config is the file given to -config.file. Every setting is optional: an
+// unset one keeps the default of its flag, and a flag given on the command
+// line overrides the file.
+type config struct {
+	Web        webSettings            `yaml:"web"`
+	Marathon   marathonConfig         `yaml:"marathon"`
+	Collectors collectorsConfig       `yaml:"collectors"`
+	Limits     limitsConfig           `yaml:"limits"`
+	Filters    filtersConfig          `yaml:"filters"`
+	Mappings   []*metricMapping       `yaml:"mappings"`
+	Modules    map[string]probeModule `yaml:"modules"`
+}
+
+type webSettings struct {
+	ListenAddress   *string        `yaml:"listen_address"`
+	TelemetryPath   *string        `yaml:"telemetry_path"`
+	ProbePath       *string        `yaml:"probe_path"`
+	ConfigFile      *string        `yaml:"config_file"`
+	ReadyStaleness  *time.Duration `yaml:"ready_staleness"`
+	ShutdownTimeout *time.Duration `yaml:"shutdown_timeout"`
+	EnableDebug     *bool          `yaml:"enable_debug"`
+	EnableLifecycle *bool          `yaml:"enable_lifecycle"`
+}
+
+// marathonConfig describes how the exporter reaches its own Marathon.
+type marathonConfig struct {
+	URI              *string        `yaml:"uri"`
+	Username         *string        `yaml:"username"`
+	Password         *string        `yaml:"password"`
+	PasswordFile     *string        `yaml:"password_file"`
+	ProxyURL         *string        `yaml:"proxy_url"`
+	TLS              *tlsConfig     `yaml:"tls_config"`
+	Retries          *int           `yaml:"retries"`
+	RetryBackoff     *time.Duration `yaml:"retry_backoff"`
+	BreakerThreshold *int           `yaml:"breaker_threshold"`
+	BreakerCooldown  *time.Duration `yaml:"breaker_cooldown"`
+	ScrapeInterval   *time.Duration `yaml:"scrape_interval"`
+	VersionInterval  *time.Duration `yaml:"version_interval"`
+	MetricsFormat    *string        `yaml:"metrics_format"`
+	Summaries        *bool          `yaml:"summaries"`
+	RawUnits         *bool          `yaml:"raw_units"`
+}
+
+type collectorsConfig struct {
+	Concurrency *int            `yaml:"concurrency"`
+	Timeout     *time.Duration  `yaml:"timeout"`
+	Apps        collectorConfig `yaml:"apps"`
+	Metrics     collectorConfig `yaml:"metrics"`
+}
+
+// collectorConfig overrides the settings of a single collector.
+type collectorConfig struct {
+	Enabled *bool         `yaml:"enabled"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (c collectorConfig) disabled() bool {
+	return c.Enabled != nil && !*c.Enabled
+}
+
+type limitsConfig struct {
+	Series       *int           `yaml:"series"`
+	FamilySeries *int           `yaml:"family_series"`
+	Families     map[string]int `yaml:"families"`
+}
+
+type filtersConfig struct {
+	Apps    appFiltersConfig    `yaml:"apps"`
+	Metrics metricFiltersConfig `yaml:"metrics"`
+}
+
+type appFiltersConfig struct {
+	Include appFilterConfig `yaml:"include"`
+	Exclude appFilterConfig `yaml:"exclude"`
+}
+
+type appFilterConfig struct {
+	ID     *string  `yaml:"id"`
+	Groups []string `yaml:"groups"`
+	Labels *string  `yaml:"labels"`
+}
+
+type metricFiltersConfig struct {
+	Include metricFilterConfig `yaml:"include"`
+	Exclude metricFilterConfig `yaml:"exclude"`
+}
+
+type metricFilterConfig struct {
+	Key   *string  `yaml:"key"`
+	Types []string `yaml:"types"`
+}
+
+// configFlag ties a setting of the configuration file to the flag it
+// overrides the default of. value points to the setting, nil when unset.
+type configFlag struct {
+	flag  string
+	value interface{}
+}
+
+func (c *config) flags() []configFlag {
+	return []configFlag{
+		{"web.listen-address", c.Web.ListenAddress},
+		{"web.telemetry-path", c.Web.TelemetryPath},
+		{"web.probe-path", c.Web.ProbePath},
+		{"web.config.file", c.Web.ConfigFile},
+		{"web.ready-staleness", c.Web.ReadyStaleness},
+		{"web.shutdown-timeout", c.Web.ShutdownTimeout},
+		{"web.enable-debug", c.Web.EnableDebug},
+		{"web.enable-lifecycle", c.Web.EnableLifecycle},
+		{"marathon.uri", c.Marathon.URI},
+		{"marathon.username", c.Marathon.Username},
+		{"marathon.password", c.Marathon.Password},
+		{"marathon.password-file", c.Marathon.PasswordFile},
+		{"marathon.proxy-url", c.Marathon.ProxyURL},
+		{"marathon.retries", c.Marathon.Retries},
+		{"marathon.retry-backoff", c.Marathon.RetryBackoff},
+		{"marathon.breaker-threshold", c.Marathon.BreakerThreshold},
+		{"marathon.breaker-cooldown", c.Marathon.BreakerCooldown},
+		{"marathon.scrape-interval", c.Marathon.ScrapeInterval},
+		{"marathon.version-interval", c.Marathon.VersionInterval},
+		{"marathon.metrics-format", c.Marathon.MetricsFormat},
+		{"marathon.summaries", c.Marathon.Summaries},
+		{"marathon.raw-units", c.Marathon.RawUnits},
+		{"marathon.collector-concurrency", c.Collectors.Concurrency},
+		{"marathon.collector-timeout", c.Collectors.Timeout},
+		{"marathon.series-limit", c.Limits.Series},
+		{"marathon.family-series-limit", c.Limits.FamilySeries},
+		{"marathon.app-include", c.Filters.Apps.Include.ID},
+		{"marathon.app-exclude", c.Filters.Apps.Exclude.ID},
+		{"marathon.app-include-groups", joinList(c.Filters.Apps.Include.Groups)},
+		{"marathon.app-exclude-groups", joinList(c.Filters.Apps.Exclude.Groups)},
+		{"marathon.app-include-labels", c.Filters.Apps.Include.Labels},
+		{"marathon.app-exclude-labels", c.Filters.Apps.Exclude.Labels},
+		{"marathon.metric-include", c.Filters.Metrics.Include.Key},
+		{"marathon.metric-exclude", c.Filters.Metrics.Exclude.Key},
+		{"marathon.metric-include-types", joinList(c.Filters.Metrics.Include.Types)},
+		{"marathon.metric-exclude-types", joinList(c.Filters.Metrics.Exclude.Types)},
+	}
+}
+
+// joinList returns the comma-separated values of a list setting, nil if
+// the list is unset.
+func joinList(values []string) *string {
+	if values == nil {
+		return nil
+	}
+	joined := strings.Join(values, ",")
+	return &joined
+}
+
+func loadConfig(path string) (*config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &config{}
+	if err := yaml.UnmarshalStrict(content, c); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("error in %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// validate checks the settings the YAML types do not constrain, reporting
+// the first invalid one by its path in the file.
+func (c *config) validate() error {
+	if c.Marathon.URI != nil {
+		if _, err := url.Parse(*c.Marathon.URI); err != nil {
+			return fmt.Errorf("marathon.uri: %v", err)
+		}
+	}
+	if c.Marathon.ProxyURL != nil {
+		if _, err := url.Parse(*c.Marathon.ProxyURL); err != nil {
+			return fmt.Errorf("marathon.proxy_url: %v", err)
+		}
+	}
+	if c.Marathon.Password != nil && c.Marathon.PasswordFile != nil {
+		return fmt.Errorf("marathon.password_file: cannot be set with marathon.password")
+	}
+	if c.Marathon.TLS != nil {
+		if _, err := (probeModule{TLS: *c.Marathon.TLS}).tlsConfig(); err != nil {
+			return fmt.Errorf("marathon.tls_config: %v", err)
+		}
+	}
+	if format := c.Marathon.MetricsFormat; format != nil {
+		switch *format {
+		case metricsFormatAuto, metricsFormatJSON, metricsFormatPrometheus:
+		default:
+			return fmt.Errorf("marathon.metrics_format: unknown format %q, expected auto, json or prometheus", *format)
+		}
+	}
+
+	positive := []struct {
+		path  string
+		value interface{}
+	}{
+		{"web.ready_staleness", c.Web.ReadyStaleness},
+		{"web.shutdown_timeout", c.Web.ShutdownTimeout},
+		{"marathon.retries", c.Marathon.Retries},
+		{"marathon.retry_backoff", c.Marathon.RetryBackoff},
+		{"marathon.breaker_threshold", c.Marathon.BreakerThreshold},
+		{"marathon.breaker_cooldown", c.Marathon.BreakerCooldown},
+		{"marathon.scrape_interval", c.Marathon.ScrapeInterval},
+		{"marathon.version_interval", c.Marathon.VersionInterval},
+		{"collectors.concurrency", c.Collectors.Concurrency},
+		{"collectors.timeout", c.Collectors.Timeout},
+		{"collectors.apps.timeout", &c.Collectors.Apps.Timeout},
+		{"collectors.metrics.timeout", &c.Collectors.Metrics.Timeout},
+		{"limits.series", c.Limits.Series},
+		{"limits.family_series", c.Limits.FamilySeries},
+	}
+	for _, setting := range positive {
+		if v := reflect.ValueOf(setting.value); !v.IsNil() && v.Elem().Int() < 0 {
+			return fmt.Errorf("%s: must not be negative, got %v", setting.path, v.Elem().Interface())
+		}
+	}
+	if c.Collectors.Concurrency != nil && *c.Collectors.Concurrency == 0 {
+		return fmt.Errorf("collectors.concurrency: must be at least 1")
+	}
+	for family, limit := range c.Limits.Families {
+		if limit < 0 {
+			return fmt.Errorf("limits.families.%s: must not be negative, got %d", family, limit)
+		}
+	}
+
+	for _, filter := range []struct {
+		path   string
+		config appFilterConfig
+	}{
+		{"filters.apps.include", c.Filters.Apps.Include},
+		{"filters.apps.exclude", c.Filters.Apps.Exclude},
+	} {
+		if filter.config.ID != nil {
+			if _, err := compileAnchored(*filter.config.ID); err != nil {
+				return fmt.Errorf("%s.id: %v", filter.path, err)
+			}
+		}
+		for _, group := range filter.config.Groups {
+			if strings.Contains(group, ",") {
+				return fmt.Errorf("%s.groups: invalid group %q", filter.path, group)
+			}
+		}
+		if _, err := splitGroups(strings.Join(filter.config.Groups, ",")); err != nil {
+			return fmt.Errorf("%s.groups: %v", filter.path, err)
+		}
+		if filter.config.Labels != nil {
+			if _, err := parseLabelSelector(*filter.config.Labels); err != nil {
+				return fmt.Errorf("%s.labels: %v", filter.path, err)
+			}
+		}
+	}
+
+	for _, filter := range []struct {
+		path   string
+		config metricFilterConfig
+	}{
+		{"filters.metrics.include", c.Filters.Metrics.Include},
+		{"filters.metrics.exclude", c.Filters.Metrics.Exclude},
+	} {
+		if filter.config.Key != nil {
+			if _, err := compileAnchored(*filter.config.Key); err != nil {
+				return fmt.Errorf("%s.key: %v", filter.path, err)
+			}
+		}
+		if _, err := parseMetricTypes(strings.Join(filter.config.Types, ",")); err != nil {
+			return fmt.Errorf("%s.types: %v", filter.path, err)
+		}
+	}
+
+	for i, mapping := range c.Mappings {
+		if err := mapping.init(); err != nil {
+			return fmt.Errorf("mappings: mapping %d (%q): %v", i+1, mapping.Match, err)
+		}
+	}
+	for name, module := range c.Modules {
+		module, err := module.resolve()
+		if err != nil {
+			return fmt.Errorf("modules.%s: %v", name, err)
+		}
+		c.Modules[name] = module
+	}
+	return nil
+}
+
+// applyConfig sets the flags not given on the command line to the values of
+// the configuration file, so that settings having a flag are only read from
+// their flag.
+func applyConfig(c *config, flags *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	for _, setting := range c.flags() {
+		v := reflect.ValueOf(setting.value)
+		if v.IsNil() || explicit[setting.flag] {
+			continue
+		}
+		if err := flags.Set(setting.flag, fmt.Sprint(v.Elem().Interface())); err != nil {
+			return fmt.Errorf("flag %s: %v", setting.flag, err)
+		}
+	}
+
+	if len(c.Limits.Families) > 0 && !explicit["marathon.family-series-limits"] {
+		if err := flags.Set("marathon.family-series-limits", familyLimits(c.Limits.Families).String()); err != nil {
+			return fmt.Errorf("flag marathon.family-series-limits: %v", err)
+		}
+	}
+	return nil
+}
+
+// collectorConfigs returns the settings of the collectors by name.
+func (c *config) collectorConfigs() map[string]collectorConfig {
+	return map[string]collectorConfig{
+		"apps":    c.Collectors.Apps,
+		"metrics": c.Collectors.Metrics,
+	}
+}
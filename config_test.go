@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_config_validation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		content string
+		expect  string
+	}{
+		{"marathon:\n  url: http://marathon\n", "line 2: field url not found"},
+		{"marathon:\n  retries: many\n", "line 2: cannot unmarshal !!str `many` into int"},
+		{"marathon:\n  retry_backoff: soon\n", "line 2: cannot unmarshal !!str `soon` into time.Duration"},
+		{"marathon:\n  uri: 'http://[::1'\n", "marathon.uri:"},
+		{"marathon:\n  password: secret\n  password_file: password\n", "marathon.password_file: cannot be set with marathon.password"},
+		{"marathon:\n  tls_config:\n    cert_file: client.pem\n", "marathon.tls_config: both cert_file and key_file"},
+		{"marathon:\n  metrics_format: xml\n", `marathon.metrics_format: unknown format "xml"`},
+		{"marathon:\n  scrape_interval: -1s\n", "marathon.scrape_interval: must not be negative, got -1s"},
+		{"collectors:\n  concurrency: 0\n", "collectors.concurrency: must be at least 1"},
+		{"collectors:\n  tasks:\n    enabled: false\n", "line 2: field tasks not found"},
+		{"collectors:\n  apps:\n    timeout: -5s\n", "collectors.apps.timeout: must not be negative"},
+		{"limits:\n  families:\n    marathon_app_instances: -1\n", "limits.families.marathon_app_instances: must not be negative"},
+		{"filters:\n  apps:\n    exclude:\n      groups: [ci]\n", `filters.apps.exclude.groups: invalid group "ci"`},
+		{"filters:\n  apps:\n    include:\n      labels: '=prod'\n", "filters.apps.include.labels: invalid requirement"},
+		{"filters:\n  metrics:\n    exclude:\n      types: [timers]\n", `filters.metrics.exclude.types: unknown metric type "timers"`},
+		{"filters:\n  metrics:\n    include:\n      key: '('\n", "filters.metrics.include.key: error parsing regexp"},
+		{"mappings:\n  - match: foo.*\n", `mappings: mapping 1 ("foo.*"): name is required`},
+		{"modules:\n  production:\n    proxy_url: ':'\n", "modules.production:"},
+	}
+	for _, c := range cases {
+		path := writeFile(t, dir, "config.yml", c.content)
+		_, err := loadConfig(path)
+		if err == nil {
+			t.Errorf("%q: expected an error", c.content)
+			continue
+		}
+		if !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), c.expect) {
+			t.Errorf("%q: expected an error of %s containing %q, got %v", c.content, path, c.expect, err)
+		}
+	}
+}
+
+func Test_config_flags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config, err := loadConfig(writeFile(t, dir, "config.yml", `
+marathon:
+  uri: http://marathon.example.com:8080
+  retries: 5
+  retry_backoff: 1s
+  summaries: true
+limits:
+  families:
+    marathon_app_instances: 100
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	uri := flags.String("marathon.uri", "http://marathon.mesos:8080", "")
+	retries := flags.Int("marathon.retries", 2, "")
+	backoff := flags.Duration("marathon.retry-backoff", 100*time.Millisecond, "")
+	summaries := flags.Bool("marathon.summaries", false, "")
+	rawUnits := flags.Bool("marathon.raw-units", false, "")
+	limits := familyLimits{}
+	flags.Var(limits, "marathon.family-series-limits", "")
+	if err := flags.Parse([]string{"-marathon.retries=7"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyConfig(config, flags); err != nil {
+		t.Fatal(err)
+	}
+	if *uri != "http://marathon.example.com:8080" {
+		t.Errorf("expected the URI of the configuration, got %s", *uri)
+	}
+	if *retries != 7 {
+		t.Errorf("expected the command line to override retries, got %d", *retries)
+	}
+	if *backoff != time.Second || !*summaries {
+		t.Errorf("expected the backoff and summaries of the configuration, got %v and %v", *backoff, *summaries)
+	}
+	if *rawUnits {
+		t.Errorf("expected raw units to keep their default")
+	}
+	if limits["marathon_app_instances"] != 100 {
+		t.Errorf("expected the family limits of the configuration, got %v", limits)
+	}
+}
+
+func Test_collector_configs(t *testing.T) {
+	disabled := false
+	e := NewExporter(&testScraper{`{}`}, "marathon")
+	e.collectorConfigs = map[string]collectorConfig{
+		"apps":    {Timeout: time.Second},
+		"metrics": {Enabled: &disabled},
+	}
+
+	collectors := e.collectors()
+	if len(collectors) != 1 || collectors[0].name != "apps" {
+		t.Fatalf("expected only the apps collector, got %v", collectors)
+	}
+	if collectors[0].timeout != time.Second {
+		t.Errorf("expected the apps collector to time out after 1s, got %v", collectors[0].timeout)
+	}
+}
@@ -18,67 +18,45 @@ const (
 	timerHelp     = "Marathon timer %s (%s)"
 )
 
-type CounterContainer struct {
-	counters  map[string]*prometheus.CounterVec
+// DescContainer caches the descriptors of the metrics exported from Marathon
+// across scrapes. Values are exported as const metrics built from these
+// descriptors, so series that disappear from Marathon are not exported again.
+type DescContainer struct {
+	descs     map[string]*prometheus.Desc
+	names     map[*prometheus.Desc]string
 	namespace string
 	mutex     sync.Mutex
 }
 
-func NewCounterContainer(namespace string) *CounterContainer {
-	return &CounterContainer{
-		counters:  make(map[string]*prometheus.CounterVec),
+func NewDescContainer(namespace string) *DescContainer {
+	return &DescContainer{
+		descs:     make(map[string]*prometheus.Desc),
+		names:     make(map[*prometheus.Desc]string),
 		namespace: namespace,
 	}
 }
 
-func (c *CounterContainer) Fetch(name, help string, labels ...string) (*prometheus.CounterVec, bool) {
+func (c *DescContainer) Fetch(name, help string, labels ...string) (*prometheus.Desc, bool) {
 	key := containerKey(name, labels)
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	counter, exists := c.counters[key]
+	desc, exists := c.descs[key]
 
 	if !exists {
-		counter = prometheus.NewCounterVec(prometheus.CounterOpts{
-			Namespace: c.namespace,
-			Name:      name,
-			Help:      help,
-		}, labels)
-
-		c.counters[key] = counter
+		fqName := prometheus.BuildFQName(c.namespace, "", name)
+		desc = prometheus.NewDesc(fqName, help, labels, nil)
+		c.descs[key] = desc
+		c.names[desc] = fqName
 	}
-
-	return counter, !exists
-}
-
-type GaugeContainer struct {
-	gauges    map[string]*prometheus.GaugeVec
-	namespace string
-	mutex     sync.Mutex
+	return desc, !exists
 }
 
-func NewGaugeContainer(namespace string) *GaugeContainer {
-	return &GaugeContainer{
-		gauges:    make(map[string]*prometheus.GaugeVec),
-		namespace: namespace,
-	}
-}
-
-func (c *GaugeContainer) Fetch(name, help string, labels ...string) (*prometheus.GaugeVec, bool) {
-	key := containerKey(name, labels)
+// Name returns the fully-qualified name of a descriptor returned by Fetch.
+func (c *DescContainer) Name(desc *prometheus.Desc) (string, bool) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	gauge, exists := c.gauges[key]
-
-	if !exists {
-		gauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: c.namespace,
-			Name:      name,
-			Help:      help,
-		}, labels)
-
-		c.gauges[key] = gauge
-	}
-	return gauge, !exists
+	name, ok := c.names[desc]
+	return name, ok
 }
 
 func containerKey(metric string, labels []string) string {
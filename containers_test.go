@@ -31,42 +31,27 @@ func Test_container_key(t *testing.T) {
 	}
 }
 
-func Test_container_fetch_counter(t *testing.T) {
-	container := NewCounterContainer("marathon")
-	_, new := container.Fetch("foo", "")
+func Test_container_fetch_desc(t *testing.T) {
+	container := NewDescContainer("marathon")
+	desc, new := container.Fetch("foo", "", "value")
 
 	if !new {
-		t.Fatal("expected a new counter")
+		t.Fatal("expected a new descriptor")
 	}
-	if len(container.counters) != 1 {
-		t.Fatalf("expected a counter, got %d counters", len(container.counters))
+	if len(container.descs) != 1 {
+		t.Fatalf("expected a descriptor, got %d descriptors", len(container.descs))
 	}
 
-	_, new = container.Fetch("foo", "")
-	if new {
-		t.Fatal("expected an existing counter")
+	same, new := container.Fetch("foo", "", "value")
+	if new || same != desc {
+		t.Fatal("expected an existing descriptor")
 	}
-	if len(container.counters) != 1 {
-		t.Fatalf("expected same counter as before, go %d counters", len(container.counters))
+	if len(container.descs) != 1 {
+		t.Fatalf("expected same descriptor as before, got %d descriptors", len(container.descs))
 	}
-}
-
-func Test_container_fetch_gauge(t *testing.T) {
-	container := NewGaugeContainer("marathon")
-	_, new := container.Fetch("foo", "")
 
+	_, new = container.Fetch("foo", "", "value", "color")
 	if !new {
-		t.Fatal("expected a new gauge")
-	}
-	if len(container.gauges) != 1 {
-		t.Fatalf("expected a gauge, got %d gauges", len(container.gauges))
-	}
-
-	_, new = container.Fetch("foo", "")
-	if new {
-		t.Fatal("expected an existing gauge")
-	}
-	if len(container.gauges) != 1 {
-		t.Fatalf("expected same gauge as before, go %d gauges", len(container.gauges))
+		t.Fatal("expected a new descriptor for different labels")
 	}
 }
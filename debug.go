@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecordedBody caps the size of the responses kept for debugging.
+const maxRecordedBody = 1 << 20
+
+// recordedResponse is the last response of Marathon to a request on an
+// endpoint.
+type recordedResponse struct {
+	path      string
+	time      time.Time
+	duration  time.Duration
+	status    string
+	header    http.Header
+	body      []byte
+	size      int
+	truncated bool
+	err       error
+}
+
+// responseRecorder keeps the last response of Marathon per endpoint, so that
+// the values exported can be checked against what Marathon returned.
+type responseRecorder struct {
+	mutex     sync.Mutex
+	responses map[string]*recordedResponse
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{responses: make(map[string]*recordedResponse)}
+}
+
+func (r *responseRecorder) record(path string, begin time.Time, response *http.Response, body []byte, err error) {
+	recorded := &recordedResponse{
+		path:     path,
+		time:     begin,
+		duration: time.Since(begin),
+		size:     len(body),
+		err:      err,
+	}
+	if response != nil {
+		recorded.status = response.Status
+		recorded.header = response.Header
+	}
+	if len(body) > maxRecordedBody {
+		body, recorded.truncated = body[:maxRecordedBody], true
+	}
+	recorded.body = append([]byte(nil), body...)
+
+	// Endpoints are recorded without their query, as in v2/apps
+	endpoint := strings.SplitN(path, "?", 2)[0]
+	r.mutex.Lock()
+	r.responses[endpoint] = recorded
+	r.mutex.Unlock()
+}
+
+func (r *responseRecorder) last(endpoint string) (*recordedResponse, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	recorded, ok := r.responses[strings.Trim(endpoint, "/")]
+	return recorded, ok
+}
+
+func (r *responseRecorder) endpoints() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	endpoints := make([]string, 0, len(r.responses))
+	for endpoint := range r.responses {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// lastResponseHandler serves the last response of Marathon on the endpoint
+// parameter, its raw body as is and the details of the request in headers.
+// Without endpoint, it lists the endpoints recorded.
+func lastResponseHandler(recorder *responseRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		endpoint := r.URL.Query().Get("endpoint")
+		if endpoint == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			for _, endpoint := range recorder.endpoints() {
+				fmt.Fprintln(w, endpoint)
+			}
+			return
+		}
+
+		recorded, ok := recorder.last(endpoint)
+		if !ok {
+			http.Error(w, fmt.Sprintf("No response recorded for endpoint %q", endpoint), http.StatusNotFound)
+			return
+		}
+
+		h := w.Header()
+		if contentType := recorded.header.Get("Content-Type"); contentType != "" {
+			h.Set("Content-Type", contentType)
+		}
+		h.Set("X-Marathon-Path", recorded.path)
+		h.Set("X-Marathon-Time", recorded.time.UTC().Format(time.RFC3339Nano))
+		h.Set("X-Marathon-Duration", recorded.duration.String())
+		h.Set("X-Marathon-Size", strconv.Itoa(recorded.size))
+		h.Set("X-Marathon-Truncated", strconv.FormatBool(recorded.truncated))
+		if recorded.status != "" {
+			h.Set("X-Marathon-Status", recorded.status)
+		}
+		if recorded.err != nil {
+			h.Set("X-Marathon-Error", recorded.err.Error())
+		}
+		w.Write(recorded.body)
+	}
+}
+
+// handleDebug registers the debug endpoints on mux.
+func handleDebug(mux *http.ServeMux, recorder *responseRecorder) {
+	mux.Handle("/debug/last-response", lastResponseHandler(recorder))
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
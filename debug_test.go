@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_last_response(t *testing.T) {
+	marathon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/apps":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"apps": []}`))
+		case "/metrics":
+			w.Write([]byte(strings.Repeat("x", maxRecordedBody+10)))
+		default:
+			http.Error(w, "Leader unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer marathon.Close()
+
+	uri, _ := url.Parse(marathon.URL)
+	recorder := newResponseRecorder()
+	s := &scraper{uri: uri, recorder: recorder}
+	for _, path := range []string{"v2/apps?embed=apps.taskStats", "metrics", "v2/info"} {
+		s.Scrape(context.Background(), path)
+	}
+
+	get := func(endpoint string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		lastResponseHandler(recorder)(w, httptest.NewRequest("GET", "/debug/last-response?endpoint="+endpoint, nil))
+		return w
+	}
+
+	w := get("v2/apps")
+	if w.Code != http.StatusOK || w.Body.String() != `{"apps": []}` {
+		t.Errorf("unexpected v2/apps response %d: %s", w.Code, w.Body)
+	}
+	if h := w.Header(); h.Get("X-Marathon-Status") != "200 OK" ||
+		h.Get("X-Marathon-Path") != "v2/apps?embed=apps.taskStats" ||
+		h.Get("Content-Type") != "application/json" ||
+		h.Get("X-Marathon-Duration") == "" {
+		t.Errorf("unexpected v2/apps headers %v", h)
+	}
+
+	w = get("metrics")
+	if w.Body.Len() != maxRecordedBody || w.Header().Get("X-Marathon-Truncated") != "true" {
+		t.Errorf("expected the metrics response to be truncated, got %d bytes", w.Body.Len())
+	}
+
+	w = get("v2/info")
+	if w.Header().Get("X-Marathon-Status") != "503 Service Unavailable" ||
+		!strings.Contains(w.Body.String(), "Leader unavailable") {
+		t.Errorf("unexpected v2/info response %v: %s", w.Header(), w.Body)
+	}
+
+	if w = get("v2/queue"); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an endpoint not scraped, got %d", w.Code)
+	}
+
+	if w = get(""); w.Body.String() != "metrics\nv2/apps\nv2/info\n" {
+		t.Errorf("unexpected list of endpoints %q", w.Body)
+	}
+}
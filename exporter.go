@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jeffail/gabs"
@@ -15,87 +19,173 @@ import (
 const defaultNamespace = "marathon"
 
 type Exporter struct {
-	scraper      Scraper
-	duration     prometheus.Gauge
-	scrapeError  prometheus.Gauge
-	up           prometheus.Gauge
-	totalErrors  prometheus.Counter
-	totalScrapes prometheus.Counter
-	Counters     *CounterContainer
-	Gauges       *GaugeContainer
+	scraper           Scraper
+	duration          prometheus.Gauge
+	scrapeError       prometheus.Gauge
+	up                prometheus.Gauge
+	totalErrors       prometheus.Counter
+	totalScrapes      prometheus.Counter
+	collectorDuration *prometheus.GaugeVec
+	collectorSuccess  *prometheus.GaugeVec
+	snapshotAge       prometheus.Gauge
+	metricCollisions  prometheus.Counter
+	Descs             *DescContainer
+	concurrency       int
+	timeout           time.Duration
+
+	// configMutex is held by scrapes, so that reloads swap the settings
+	// of the exporter between them.
+	configMutex sync.RWMutex
+
+	// collectorConfigs disables collectors or overrides their timeout.
+	collectorConfigs map[string]collectorConfig
+
+	// ctx cancels the requests to Marathon once the exporter stops.
+	ctx context.Context
+
+	// summaries exports Dropwizard histograms and timers as summaries
+	// rather than one gauge per statistic.
+	summaries bool
+
+	// rawUnits exports timers and meters in the units reported by Marathon
+	// instead of seconds and rates per second.
+	rawUnits bool
+
+	// mapper turns Dropwizard metric names into metric names and labels.
+	mapper *metricMapper
+
+	// appFilter selects the apps exported, all of them if nil.
+	appFilter *appFilter
+
+	// metricFilter selects the Dropwizard metrics exported, all of them if
+	// nil.
+	metricFilter    *metricFilter
+	metricsFiltered *prometheus.CounterVec
+
+	// metricsFormat selects between Marathon's JSON metrics and the
+	// Prometheus metrics of Marathon 1.7+, by default based on the version.
+	metricsFormat string
+
+	// versionInterval is how often the version of Marathon, which selects
+	// the parser of its endpoints, is detected again.
+	versionInterval    time.Duration
+	unsupportedVersion prometheus.Gauge
+	versionMutex       sync.Mutex
+	version            marathonVersion
+	versionKnown       bool
+	versionNext        time.Time
+	leader             string
+
+	// limits caps the number of series exported from Marathon.
+	limits        seriesLimits
+	seriesDropped *prometheus.CounterVec
+
+	// status holds the outcome of the last scrape, for the status page.
+	statusMutex sync.Mutex
+	status      scrapeStatus
+
+	// lastContact is when Marathon last answered, for readiness checks.
+	contactMutex sync.Mutex
+	lastContact  time.Time
+
+	// warned holds the problems already logged as warnings.
+	warnedMutex sync.Mutex
+	warned      map[string]bool
+
+	// background is set when a loop keeps the snapshot up to date, in which
+	// case Collect serves it instead of scraping Marathon.
+	background bool
+	mutex      sync.Mutex
+	last       *snapshot
+	inflight   *flight
 }
 
-// Describe implements prometheus.Collector.
+// Describe implements prometheus.Collector. The metrics of Marathon are only
+// known once it is scraped, so only the metrics of the exporter itself are
+// described: registering the exporter must not wait for Marathon.
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	log.Debugln("Describing metrics")
-	metricCh := make(chan prometheus.Metric)
-	doneCh := make(chan struct{})
-
-	go func() {
-		for m := range metricCh {
-			ch <- m.Desc()
-		}
-		close(doneCh)
-	}()
-
-	e.Collect(metricCh)
-	close(metricCh)
-	<-doneCh
+	for _, c := range []prometheus.Collector{
+		e.duration, e.totalScrapes, e.totalErrors, e.scrapeError, e.up,
+		e.unsupportedVersion, e.metricCollisions, e.collectorDuration,
+		e.collectorSuccess, e.seriesDropped, e.metricsFiltered, e.snapshotAge,
+	} {
+		c.Describe(ch)
+	}
 }
 
 // Collect implements prometheus.Collector.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	log.Debugln("Collecting metrics")
-	e.scrape(ch)
+	var s *snapshot
+	if e.background {
+		s = e.latest()
+	} else {
+		s = e.refresh()
+	}
 
-	ch <- e.duration
-	ch <- e.totalScrapes
-	ch <- e.totalErrors
-	ch <- e.scrapeError
-	ch <- e.up
+	for _, m := range s.metrics {
+		ch <- m
+	}
+	e.snapshotAge.Set(time.Since(s.time).Seconds())
+	ch <- e.snapshotAge
 }
 
 func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
+	e.configMutex.RLock()
+	defer e.configMutex.RUnlock()
 	e.totalScrapes.Inc()
 
-	var err error
+	var succeeded, failed int
 	defer func(begin time.Time) {
 		e.duration.Set(time.Since(begin).Seconds())
-		if err == nil {
+		if failed == 0 {
 			e.scrapeError.Set(0)
-			e.up.Set(1)
 		} else {
 			e.totalErrors.Inc()
 			e.scrapeError.Set(1)
+		}
+		if succeeded > 0 {
+			e.contacted(time.Now())
+			e.up.Set(1)
+		} else {
 			e.up.Set(0)
 		}
 	}(time.Now())
 
-	// Rebuild gauges & coutners to avoid stale values
-	e.Gauges = NewGaugeContainer(e.Gauges.namespace)
-	e.Counters = NewCounterContainer(e.Counters.namespace)
-	if err = e.exportApps(ch); err != nil {
-		return
-	}
-	if err = e.exportMetrics(ch); err != nil {
-		return
+	// A failing collector must not prevent the others from being exported
+	results := e.collect(e.collectors(), ch)
+	e.recordResults(results)
+	for _, result := range results {
+		e.collectorDuration.WithLabelValues(result.name).Set(result.duration.Seconds())
+		if result.err != nil {
+			log.Errorf("Collector %q failed after %v: %v\n", result.name, result.duration, result.err)
+			e.collectorSuccess.WithLabelValues(result.name).Set(0)
+			failed++
+		} else {
+			e.collectorSuccess.WithLabelValues(result.name).Set(1)
+			succeeded++
+		}
 	}
+}
 
-	e.Counters.mutex.Lock()
-	defer e.Counters.mutex.Unlock()
-	for _, counter := range e.Counters.counters {
-		counter.Collect(ch)
+func (e *Exporter) exportApps(ctx context.Context, ch chan<- prometheus.Metric) error {
+	p, err := e.parser()
+	if err != nil {
+		return err
 	}
+	return p.apps(e, ctx, ch)
+}
 
-	e.Gauges.mutex.Lock()
-	defer e.Gauges.mutex.Unlock()
-	for _, gauge := range e.Gauges.gauges {
-		gauge.Collect(ch)
+func (e *Exporter) exportMetrics(ctx context.Context, ch chan<- prometheus.Metric) error {
+	p, err := e.parser()
+	if err != nil {
+		return err
 	}
+	return p.metrics(e, ctx, ch)
 }
 
-func (e *Exporter) exportApps(ch chan<- prometheus.Metric) (err error) {
-	content, err := e.scraper.Scrape("v2/apps?embed=apps.taskStats")
+func (e *Exporter) exportV2Apps(ctx context.Context, ch chan<- prometheus.Metric) (err error) {
+	content, err := e.scraper.Scrape(ctx, "v2/apps?embed=apps.taskStats")
 	if err != nil {
 		log.Debugf("Problem scraping v2/apps endpoint: %v\n", err)
 		return
@@ -111,8 +201,36 @@ func (e *Exporter) exportApps(ch chan<- prometheus.Metric) (err error) {
 	return
 }
 
-func (e *Exporter) exportMetrics(ch chan<- prometheus.Metric) (err error) {
-	content, err := e.scraper.Scrape("metrics")
+// exportCurrentMetrics exports the metrics of Marathon 1.7+, re-exposing
+// its Prometheus metrics unless the JSON format is requested.
+func (e *Exporter) exportCurrentMetrics(ctx context.Context, ch chan<- prometheus.Metric) error {
+	if e.metricsFormat == metricsFormatJSON {
+		return e.exportJSONMetrics(ctx, ch)
+	}
+	return e.exportPrometheusMetrics(ctx, ch)
+}
+
+// exportLegacyMetrics exports the metrics of Marathon 1.5 and 1.6, which
+// have no Prometheus endpoint.
+func (e *Exporter) exportLegacyMetrics(ctx context.Context, ch chan<- prometheus.Metric) error {
+	if e.metricsFormat == metricsFormatPrometheus {
+		version, _ := e.detectVersion()
+		return fmt.Errorf("Marathon %v has no metrics in the Prometheus format, 1.7 or later is required", version)
+	}
+	return e.exportJSONMetrics(ctx, ch)
+}
+
+// exportUnknownMetrics exports the metrics of a Marathon of unknown version
+// in the requested format, the JSON one by default.
+func (e *Exporter) exportUnknownMetrics(ctx context.Context, ch chan<- prometheus.Metric) error {
+	if e.metricsFormat == metricsFormatPrometheus {
+		return e.exportPrometheusMetrics(ctx, ch)
+	}
+	return e.exportJSONMetrics(ctx, ch)
+}
+
+func (e *Exporter) exportJSONMetrics(ctx context.Context, ch chan<- prometheus.Metric) (err error) {
+	content, err := e.scraper.Scrape(ctx, "metrics")
 	if err != nil {
 		log.Debugf("Problem scraping metrics endpoint: %v\n", err)
 		return
@@ -128,42 +246,57 @@ func (e *Exporter) exportMetrics(ch chan<- prometheus.Metric) (err error) {
 	return
 }
 
+// sortedKeys returns the keys of elements in order, so that a scrape exports
+// metrics in the same order every time.
+func sortedKeys(elements map[string]*gabs.Container) []string {
+	keys := make([]string, 0, len(elements))
+	for key := range elements {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appStates maps the app_task_* gauges to their path in a v2/apps app.
+var appStates = map[string]string{
+	"running":    "tasksRunning",
+	"staged":     "tasksStaged",
+	"healthy":    "tasksHealthy",
+	"unhealthy":  "tasksUnhealthy",
+	"cpus":       "cpus",
+	"mem_in_mb":  "mem",
+	"disk_in_mb": "disk",
+	"gpus":       "gpus",
+	"avg_uptime": "taskStats.startedAfterLastScaling.stats.lifeTime.averageSeconds",
+}
+
 func (e *Exporter) scrapeApps(json *gabs.Container, ch chan<- prometheus.Metric) {
 	elements, _ := json.S("apps").Children()
-	states := map[string]string{
-		"running":    "tasksRunning",
-		"staged":     "tasksStaged",
-		"healthy":    "tasksHealthy",
-		"unhealthy":  "tasksUnhealthy",
-		"cpus":       "cpus",
-		"mem_in_mb":  "mem",
-		"disk_in_mb": "disk",
-		"gpus":       "gpus",
-		"avg_uptime": "taskStats.startedAfterLastScaling.stats.lifeTime.averageSeconds",
-	}
 
 	name := "app_instances"
-	gauge, new := e.Gauges.Fetch(name, "Marathon app instance count", "app", "app_version")
+	instances, new := e.Descs.Fetch(name, "Marathon app instance count", "app", "app_version")
 	if new {
 		log.Infof("Added gauge %q\n", name)
 	}
-	gauge.Reset()
 
 	for _, app := range elements {
 		id := app.Path("id").Data().(string)
+		if e.appFilter != nil && !e.appFilter.matches(id, appLabels(app)) {
+			continue
+		}
 		version := app.Path("version").Data().(string)
 		data := app.Path("instances").Data()
 		count, ok := data.(float64)
 		if !ok {
-			log.Debugf(fmt.Sprintf("Bad conversion! Unexpected value \"%v\" for number of app instances\n", data))
+			log.Debugf("Bad conversion! Unexpected value \"%v\" for number of app instances\n", data)
 			continue
 		}
 
-		gauge.WithLabelValues(id, version).Set(count)
+		ch <- prometheus.MustNewConstMetric(instances, prometheus.GaugeValue, count, id, version)
 
-		for key, value := range states {
+		for key, value := range appStates {
 			name := fmt.Sprintf("app_task_%s", key)
-			gauge, new := e.Gauges.Fetch(name, fmt.Sprintf("Marathon app task %s count", key), "app", "app_version")
+			desc, new := e.Descs.Fetch(name, fmt.Sprintf("Marathon app task %s count", key), "app", "app_version")
 			if new {
 				log.Infof("Added gauge %q\n", name)
 			}
@@ -171,51 +304,53 @@ func (e *Exporter) scrapeApps(json *gabs.Container, ch chan<- prometheus.Metric)
 			data := app.Path(value).Data()
 			count, ok := data.(float64)
 			if !ok {
-				log.Debugf(fmt.Sprintf("Bad conversion! Unexpected value \"%v\" for number of \"%s\" tasks\n", data, key))
+				log.Debugf("Bad conversion! Unexpected value \"%v\" for number of \"%s\" tasks\n", data, key)
 				continue
 			}
 
-			gauge.WithLabelValues(id, version).Set(count)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, count, id, version)
 		}
 	}
 }
 
 func (e *Exporter) scrapeMetrics(json *gabs.Container, ch chan<- prometheus.Metric) {
 	elements, _ := json.ChildrenMap()
-	for key, element := range elements {
+	if message, ok := elements["message"]; ok {
+		log.Errorf("Problem collecting metrics: %s\n", message.Data().(string))
+		return
+	}
+
+	for _, key := range sortedKeys(elements) {
+		element := elements[key]
 		switch key {
-		case "message":
-			log.Errorf("Problem collecting metrics: %s\n", element.Data().(string))
-			return
 		case "version":
 			data := element.Data()
 			version, ok := data.(string)
 			if !ok {
-				log.Errorf(fmt.Sprintf("Bad conversion! Unexpected value \"%v\" for version\n", data))
+				log.Errorf("Bad conversion! Unexpected value \"%v\" for version\n", data)
 			} else {
-				gauge, _ := e.Gauges.Fetch("metrics_version", "Marathon metrics version", "version")
-				gauge.WithLabelValues(version).Set(1)
-				gauge.Collect(ch)
+				desc, _ := e.Descs.Fetch("metrics_version", "Marathon metrics version", "version")
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, version)
 			}
 
 		case "counters":
-			e.scrapeCounters(element)
+			e.scrapeCounters(element, ch)
 		case "gauges":
-			e.scrapeGauges(element)
+			e.scrapeGauges(element, ch)
 		case "histograms":
-			e.scrapeHistograms(element)
+			e.scrapeHistograms(element, ch)
 		case "meters":
-			e.scrapeMeters(element)
+			e.scrapeMeters(element, ch)
 		case "timers":
-			e.scrapeTimers(element)
+			e.scrapeTimers(element, ch)
 		}
 	}
 }
 
-func (e *Exporter) scrapeCounters(json *gabs.Container) {
+func (e *Exporter) scrapeCounters(json *gabs.Container, ch chan<- prometheus.Metric) {
 	elements, _ := json.ChildrenMap()
-	for key, element := range elements {
-		new, err := e.scrapeCounter(key, element)
+	for _, key := range e.filteredKeys("counters", elements) {
+		new, err := e.scrapeCounter(key, elements[key], ch)
 		if err != nil {
 			log.Debug(err)
 		} else if new {
@@ -224,24 +359,24 @@ func (e *Exporter) scrapeCounters(json *gabs.Container) {
 	}
 }
 
-func (e *Exporter) scrapeCounter(key string, json *gabs.Container) (bool, error) {
+func (e *Exporter) scrapeCounter(key string, json *gabs.Container, ch chan<- prometheus.Metric) (bool, error) {
 	data := json.Path("count").Data()
 	count, ok := data.(float64)
 	if !ok {
 		return false, errors.New(fmt.Sprintf("Bad conversion! Unexpected value \"%v\" for counter %s\n", data, key))
 	}
 
-	name := renameMetric(key)
+	metric := e.dropwizardMetric(key)
 	help := fmt.Sprintf(counterHelp, key)
-	counter, new := e.Counters.Fetch(name, help)
-	counter.WithLabelValues().Set(count)
+	desc, new := e.Descs.Fetch(metric.name, help, metric.labels...)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, count, metric.values...)
 	return new, nil
 }
 
-func (e *Exporter) scrapeGauges(json *gabs.Container) {
+func (e *Exporter) scrapeGauges(json *gabs.Container, ch chan<- prometheus.Metric) {
 	elements, _ := json.ChildrenMap()
-	for key, element := range elements {
-		new, err := e.scrapeGauge(key, element)
+	for _, key := range e.filteredKeys("gauges", elements) {
+		new, err := e.scrapeGauge(key, elements[key], ch)
 		if err != nil {
 			log.Debug(err)
 		} else if new {
@@ -250,7 +385,7 @@ func (e *Exporter) scrapeGauges(json *gabs.Container) {
 	}
 }
 
-func (e *Exporter) scrapeGauge(key string, json *gabs.Container) (bool, error) {
+func (e *Exporter) scrapeGauge(key string, json *gabs.Container, ch chan<- prometheus.Metric) (bool, error) {
 	value, ok := json.Path("value").Data().(float64)
 	if !ok {
 		// Let's try to scrap old min,max metric
@@ -260,17 +395,17 @@ func (e *Exporter) scrapeGauge(key string, json *gabs.Container) (bool, error) {
 		}
 	}
 
-	name := renameMetric(key)
+	metric := e.dropwizardMetric(key)
 	help := fmt.Sprintf(gaugeHelp, key)
-	gauge, new := e.Gauges.Fetch(name, help)
-	gauge.WithLabelValues().Set(value)
+	desc, new := e.Descs.Fetch(metric.name, help, metric.labels...)
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, metric.values...)
 	return new, nil
 }
 
-func (e *Exporter) scrapeMeters(json *gabs.Container) {
+func (e *Exporter) scrapeMeters(json *gabs.Container, ch chan<- prometheus.Metric) {
 	elements, _ := json.ChildrenMap()
-	for key, element := range elements {
-		new, err := e.scrapeMeter(key, element)
+	for _, key := range e.filteredKeys("meters", elements) {
+		new, err := e.scrapeMeter(key, elements[key], ch)
 		if err != nil {
 			log.Debug(err)
 		} else if new {
@@ -279,7 +414,7 @@ func (e *Exporter) scrapeMeters(json *gabs.Container) {
 	}
 }
 
-func (e *Exporter) scrapeMeter(key string, json *gabs.Container) (bool, error) {
+func (e *Exporter) scrapeMeter(key string, json *gabs.Container, ch chan<- prometheus.Metric) (bool, error) {
 	count, ok := json.Path("count").Data().(float64)
 	if !ok {
 		return false, errors.New(fmt.Sprintf("Bad meter! %s has no count\n", key))
@@ -289,17 +424,24 @@ func (e *Exporter) scrapeMeter(key string, json *gabs.Container) (bool, error) {
 		return false, errors.New(fmt.Sprintf("Bad meter! %s has no units\n", key))
 	}
 
-	name := renameMetric(key)
+	metric := e.dropwizardMetric(key)
+	ratesName, scale := metric.name, 1.0
+	if !e.rawUnits {
+		if s, ok := rateScaleOf(units); ok {
+			ratesName, scale, units = withSuffix(metric.name, "per_second"), s, perSecond(units)
+		}
+	}
+
 	help := fmt.Sprintf(meterHelp, key, units)
-	counter, new := e.Counters.Fetch(name+"_count", help)
-	counter.WithLabelValues().Set(count)
+	counter, new := e.Descs.Fetch(metric.name+"_count", help, metric.labels...)
+	ch <- prometheus.MustNewConstMetric(counter, prometheus.CounterValue, count, metric.values...)
 
-	gauge, _ := e.Gauges.Fetch(name, help, "rate")
+	rates, _ := e.Descs.Fetch(ratesName, help, metric.labelNames("rate")...)
 	properties, _ := json.ChildrenMap()
 	for key, property := range properties {
 		if strings.Contains(key, "rate") {
 			if value, ok := property.Data().(float64); ok {
-				gauge.WithLabelValues(renameRate(key)).Set(value)
+				ch <- prometheus.MustNewConstMetric(rates, prometheus.GaugeValue, value*scale, metric.labelValues(renameRate(key))...)
 			}
 		}
 	}
@@ -307,10 +449,10 @@ func (e *Exporter) scrapeMeter(key string, json *gabs.Container) (bool, error) {
 	return new, nil
 }
 
-func (e *Exporter) scrapeHistograms(json *gabs.Container) {
+func (e *Exporter) scrapeHistograms(json *gabs.Container, ch chan<- prometheus.Metric) {
 	elements, _ := json.ChildrenMap()
-	for key, element := range elements {
-		new, err := e.scrapeHistogram(key, element)
+	for _, key := range e.filteredKeys("histograms", elements) {
+		new, err := e.scrapeHistogram(key, elements[key], ch)
 		if err != nil {
 			log.Debug(err)
 		} else if new {
@@ -319,56 +461,20 @@ func (e *Exporter) scrapeHistograms(json *gabs.Container) {
 	}
 }
 
-func (e *Exporter) scrapeHistogram(key string, json *gabs.Container) (bool, error) {
+func (e *Exporter) scrapeHistogram(key string, json *gabs.Container, ch chan<- prometheus.Metric) (bool, error) {
 	count, ok := json.Path("count").Data().(float64)
 	if !ok {
 		return false, errors.New(fmt.Sprintf("Bad historgram! %s has no count\n", key))
 	}
 
-	name := renameMetric(key)
 	help := fmt.Sprintf(histogramHelp, key)
-	counter, new := e.Counters.Fetch(name+"_count", help)
-	counter.WithLabelValues().Set(count)
-
-	percentiles, _ := e.Gauges.Fetch(name, help, "percentile")
-	max, _ := e.Gauges.Fetch(name+"_max", help)
-	mean, _ := e.Gauges.Fetch(name+"_mean", help)
-	min, _ := e.Gauges.Fetch(name+"_min", help)
-	stddev, _ := e.Gauges.Fetch(name+"_stddev", help)
-
-	properties, _ := json.ChildrenMap()
-	for key, property := range properties {
-		switch key {
-		case "p50", "p75", "p95", "p98", "p99", "p999":
-			if value, ok := property.Data().(float64); ok {
-				percentiles.WithLabelValues("0." + key[1:]).Set(value)
-			}
-		case "min":
-			if value, ok := property.Data().(float64); ok {
-				min.WithLabelValues().Set(value)
-			}
-		case "max":
-			if value, ok := property.Data().(float64); ok {
-				max.WithLabelValues().Set(value)
-			}
-		case "mean":
-			if value, ok := property.Data().(float64); ok {
-				mean.WithLabelValues().Set(value)
-			}
-		case "stddev":
-			if value, ok := property.Data().(float64); ok {
-				stddev.WithLabelValues().Set(value)
-			}
-		}
-	}
-
-	return new, nil
+	return e.scrapeSnapshot(e.dropwizardMetric(key), help, count, 1, json, ch), nil
 }
 
-func (e *Exporter) scrapeTimers(json *gabs.Container) {
+func (e *Exporter) scrapeTimers(json *gabs.Container, ch chan<- prometheus.Metric) {
 	elements, _ := json.ChildrenMap()
-	for key, element := range elements {
-		new, err := e.scrapeTimer(key, element)
+	for _, key := range e.filteredKeys("timers", elements) {
+		new, err := e.scrapeTimer(key, elements[key], ch)
 		if err != nil {
 			log.Debug(err)
 		} else if new {
@@ -377,7 +483,7 @@ func (e *Exporter) scrapeTimers(json *gabs.Container) {
 	}
 }
 
-func (e *Exporter) scrapeTimer(key string, json *gabs.Container) (bool, error) {
+func (e *Exporter) scrapeTimer(key string, json *gabs.Container, ch chan<- prometheus.Metric) (bool, error) {
 	count, ok := json.Path("count").Data().(float64)
 	if !ok {
 		return false, errors.New(fmt.Sprintf("Bad timer! %s has no count\n", key))
@@ -387,57 +493,111 @@ func (e *Exporter) scrapeTimer(key string, json *gabs.Container) (bool, error) {
 		return false, errors.New(fmt.Sprintf("Bad timer! %s has no units\n", key))
 	}
 
-	name := renameMetric(key)
-	help := fmt.Sprintf(timerHelp, key, units)
-	counter, new := e.Counters.Fetch(name+"_count", help)
-	counter.WithLabelValues().Set(count)
-
-	rates, _ := e.Gauges.Fetch(name+"_rate", help, "rate")
-	percentiles, _ := e.Gauges.Fetch(name, help, "percentile")
-	min, _ := e.Gauges.Fetch(name+"_min", help)
-	max, _ := e.Gauges.Fetch(name+"_max", help)
-	mean, _ := e.Gauges.Fetch(name+"_mean", help)
-	stddev, _ := e.Gauges.Fetch(name+"_stddev", help)
+	// Durations are converted to seconds and rates to rates per second,
+	// unless raw units are requested or the units are unknown
+	metric := e.dropwizardMetric(key)
+	durations, durationScale := metric, 1.0
+	ratesName, rateScale := metric.name+"_rate", 1.0
+	if !e.rawUnits {
+		durationUnits, _ := json.Path("duration_units").Data().(string)
+		if s, ok := durationScaleOf(durationUnits); ok {
+			durations.name, durationScale = withSuffix(metric.name, "seconds"), s
+		}
+		if s, ok := rateScaleOf(units); ok {
+			ratesName, rateScale, units = withSuffix(metric.name, "per_second"), s, perSecond(units)
+		}
+	}
 
+	help := fmt.Sprintf(timerHelp, key, units)
+	rates, _ := e.Descs.Fetch(ratesName, help, metric.labelNames("rate")...)
 	properties, _ := json.ChildrenMap()
 	for key, property := range properties {
 		switch key {
 		case "mean_rate", "m1_rate", "m5_rate", "m15_rate":
 			if value, ok := property.Data().(float64); ok {
-				rates.WithLabelValues(renameRate(key)).Set(value)
+				ch <- prometheus.MustNewConstMetric(rates, prometheus.GaugeValue, value*rateScale, metric.labelValues(renameRate(key))...)
 			}
+		}
+	}
+
+	return e.scrapeSnapshot(durations, help, count, durationScale, json, ch), nil
+}
+
+// scrapeSnapshot exports the count, percentiles, min, max, mean and standard
+// deviation shared by histograms and timers, either as separate gauges or as
+// a summary with companion gauges. Values are multiplied by scale.
+func (e *Exporter) scrapeSnapshot(metric dropwizardMetric, help string, count, scale float64, json *gabs.Container, ch chan<- prometheus.Metric) bool {
+	if e.summaries {
+		return e.scrapeSummary(metric, help, count, scale, json, ch)
+	}
+
+	counter, new := e.Descs.Fetch(metric.name+"_count", help, metric.labels...)
+	ch <- prometheus.MustNewConstMetric(counter, prometheus.CounterValue, count, metric.values...)
+
+	percentiles, _ := e.Descs.Fetch(metric.name, help, metric.labelNames("percentile")...)
+	properties, _ := json.ChildrenMap()
+	for key, property := range properties {
+		value, ok := property.Data().(float64)
+		if !ok {
+			continue
+		}
+		value *= scale
 
+		switch key {
 		case "p50", "p75", "p95", "p98", "p99", "p999":
-			if value, ok := property.Data().(float64); ok {
-				percentiles.WithLabelValues("0." + key[1:]).Set(value)
-			}
-		case "min":
-			if value, ok := property.Data().(float64); ok {
-				min.WithLabelValues().Set(value)
-			}
-		case "max":
-			if value, ok := property.Data().(float64); ok {
-				max.WithLabelValues().Set(value)
-			}
+			ch <- prometheus.MustNewConstMetric(percentiles, prometheus.GaugeValue, value, metric.labelValues("0."+key[1:])...)
+		case "min", "max", "mean", "stddev":
+			desc, _ := e.Descs.Fetch(metric.name+"_"+key, help, metric.labels...)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, metric.values...)
+		}
+	}
+	return new
+}
+
+// scrapeSummary exports a histogram or timer as a summary, deriving its sum
+// from the mean and count. Min, max and standard deviation have no place in
+// a summary and are kept as gauges.
+func (e *Exporter) scrapeSummary(metric dropwizardMetric, help string, count, scale float64, json *gabs.Container, ch chan<- prometheus.Metric) bool {
+	quantiles := make(map[float64]float64)
+	var mean float64
+	properties, _ := json.ChildrenMap()
+	for key, property := range properties {
+		value, ok := property.Data().(float64)
+		if !ok {
+			continue
+		}
+		value *= scale
+
+		switch key {
+		case "p50", "p75", "p95", "p98", "p99", "p999":
+			quantile, _ := strconv.ParseFloat("0."+key[1:], 64)
+			quantiles[quantile] = value
 		case "mean":
-			if value, ok := property.Data().(float64); ok {
-				mean.WithLabelValues().Set(value)
-			}
-		case "stddev":
-			if value, ok := property.Data().(float64); ok {
-				stddev.WithLabelValues().Set(value)
-			}
+			mean = value
+		case "min", "max", "stddev":
+			desc, _ := e.Descs.Fetch(metric.name+"_"+key, help, metric.labels...)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, metric.values...)
 		}
 	}
 
-	return new, nil
+	summary, new := e.Descs.Fetch(metric.name, help, metric.labels...)
+	ch <- prometheus.MustNewConstSummary(summary, uint64(count), mean*count, quantiles, metric.values...)
+	return new
 }
 
 func NewExporter(s Scraper, namespace string) *Exporter {
 	return &Exporter{
-		scraper:  s,
-		Counters: NewCounterContainer(namespace),
-		Gauges:   NewGaugeContainer(namespace),
+		scraper:     s,
+		Descs:       NewDescContainer(namespace),
+		concurrency: defaultCollectorConcurrency,
+		timeout:     defaultCollectorTimeout,
+		ctx:         context.Background(),
+
+		metricsFormat:   metricsFormatAuto,
+		versionInterval: defaultVersionInterval,
+
+		warned: make(map[string]bool),
+
 		duration: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: "exporter",
@@ -447,13 +607,13 @@ func NewExporter(s Scraper, namespace string) *Exporter {
 		up: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "up",
-			Help:      "Whether the last scrape of metrics from Marathon resulted in an error (0 for error, 1 for success).",
+			Help:      "Whether Marathon answered the last scrape (0 if every collector failed, 1 otherwise).",
 		}),
 		scrapeError: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: "exporter",
 			Name:      "last_scrape_error",
-			Help:      "Whether the last scrape of metrics from Marathon resulted in an error (1 for error, 0 for success).",
+			Help:      "Whether any collector of the last scrape of metrics from Marathon resulted in an error (1 for error, 0 for success).",
 		}),
 		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: namespace,
@@ -467,5 +627,47 @@ func NewExporter(s Scraper, namespace string) *Exporter {
 			Name:      "errors_total",
 			Help:      "Total number of times the exporter experienced errors collecting Marathon metrics.",
 		}),
+		collectorDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "collector_duration_seconds",
+			Help:      "Duration of the last scrape of a Marathon endpoint by a collector.",
+		}, []string{"collector"}),
+		collectorSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "collector_success",
+			Help:      "Whether the last scrape of a Marathon endpoint by a collector succeeded (1 for success, 0 for error).",
+		}, []string{"collector"}),
+		unsupportedVersion: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "unsupported_marathon_version",
+			Help:      "Whether the detected version of Marathon is too old to be scraped (1 for unsupported, 0 otherwise).",
+		}),
+		metricCollisions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "metric_collisions_total",
+			Help:      "Total number of metrics dropped because their name and labels collided with another metric of the same scrape.",
+		}),
+		seriesDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "series_dropped_total",
+			Help:      "Total number of series dropped because their metric family or the whole scrape exceeded its series limit.",
+		}, []string{"family"}),
+		metricsFiltered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "metrics_filtered_total",
+			Help:      "Total number of Dropwizard metrics of Marathon not exported because of the metric filters.",
+		}, []string{"type"}),
+		snapshotAge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "snapshot_age_seconds",
+			Help:      "Time elapsed since the served metrics were scraped from Marathon.",
+		}),
 	}
 }
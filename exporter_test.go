@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"io/ioutil"
 	"net/http"
@@ -26,7 +28,14 @@ type testExporter struct {
 	server   *httptest.Server
 }
 
-func (s *testScraper) Scrape(path string) ([]byte, error) {
+// testMarathonInfo is the v2/info response of the Marathon emulated by
+// testScraper, which answers every other path with the same results.
+const testMarathonInfo = `{"name": "marathon", "version": "1.5.0"}`
+
+func (s *testScraper) Scrape(ctx context.Context, path string) ([]byte, error) {
+	if path == "v2/info" {
+		return []byte(testMarathonInfo), nil
+	}
 	return []byte(s.results), nil
 }
 
@@ -47,8 +56,11 @@ func (te *testExporter) close() {
 }
 
 func (te *testExporter) export(json string) ([]byte, error) {
-
 	te.exporter.scraper = &testScraper{json}
+	return te.get()
+}
+
+func (te *testExporter) get() ([]byte, error) {
 	response, err := http.Get(te.server.URL)
 	if err != nil {
 		return nil, err
@@ -376,3 +388,133 @@ func Test_export_timers(t *testing.T) {
 	assertResultsDoNotContain(t, results,
 		fName+"_bar_timer")
 }
+
+type failingScraper struct {
+	results string
+	failing string
+}
+
+func (s *failingScraper) Scrape(ctx context.Context, path string) ([]byte, error) {
+	if strings.HasPrefix(path, s.failing) {
+		return nil, errors.New("failing endpoint " + path)
+	}
+	return []byte(s.results), nil
+}
+
+func Test_export_partial_failure(t *testing.T) {
+
+	fName := getFunctionName()
+	te := newTestExporter(fName)
+	defer te.close()
+
+	te.exporter.scraper = &failingScraper{results: `{
+		"counters": {
+			"foo_count": {"count": 1}
+		}
+	}`, failing: "v2/apps"}
+
+	results, err := te.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertResultsContain(t, results,
+		fName+"_foo_count 1",
+		fName+"_up 1",
+		fName+"_exporter_last_scrape_error 1",
+		fName+`_exporter_collector_success\{collector="apps"\} 0`,
+		fName+`_exporter_collector_success\{collector="metrics"\} 1`,
+		fName+`_exporter_collector_duration_seconds\{collector="apps"\}`)
+
+	te.exporter.scraper = &failingScraper{failing: ""}
+	results, err = te.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertResultsContain(t, results,
+		fName+"_up 0")
+}
+
+func Test_export_summaries(t *testing.T) {
+
+	fName := getFunctionName()
+	te := newTestExporter(fName)
+	defer te.close()
+	te.exporter.summaries = true
+
+	results, err := te.export(`{
+		"histograms": {
+			"foo_histogram": {"count":4,"p50":1,"p75":2,"p95":3,"p98":4,"p99":5,"p999":6,"max":7,"mean":1.5,"min":0.5,"stddev":1}
+		},
+		"timers": {
+			"bar_timer": {"count":2,"p50":2,"p75":2,"p95":2,"p98":2,"p99":2,"p999":2,"max":2,"mean":2,"min":2,"stddev":2,"m1_rate":2,"m5_rate":2,"m15_rate":2,"mean_rate":2,"duration_units":"bars","rate_units":"foos/bar"}
+		}
+	}`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertResultsContain(t, results,
+		"# TYPE "+fName+"_foo_histogram summary",
+		fName+`_foo_histogram\{quantile="0.5"\} 1`,
+		fName+`_foo_histogram\{quantile="0.999"\} 6`,
+		fName+"_foo_histogram_sum 6",
+		fName+"_foo_histogram_count 4",
+		fName+"_foo_histogram_min 0.5",
+		fName+"_foo_histogram_max 7",
+		fName+"_foo_histogram_stddev 1",
+		"# TYPE "+fName+"_bar_timer summary",
+		fName+"_bar_timer_sum 4",
+		fName+"_bar_timer_count 2",
+		fName+"_bar_timer_rate{rate=\"(1m|5m|15m|mean)\"} 2")
+
+	assertResultsDoNotContain(t, results,
+		fName+"_foo_histogram_mean",
+		fName+`_\w+\{percentile=`)
+}
+
+func Test_export_base_units(t *testing.T) {
+
+	fName := getFunctionName()
+	te := newTestExporter(fName)
+	defer te.close()
+
+	json := `{
+		"meters": {
+			"foo_meter": {"count":1,"m1_rate":120,"m5_rate":120,"m15_rate":120,"mean_rate":120,"units":"events/minute"}
+		},
+		"timers": {
+			"bar_timer": {"count":2,"p50":250,"p75":250,"p95":250,"p98":250,"p99":250,"p999":250,"max":250,"mean":250,"min":250,"stddev":250,"m1_rate":2,"m5_rate":2,"m15_rate":2,"mean_rate":2,"duration_units":"milliseconds","rate_units":"calls/second"}
+		}
+	}`
+
+	results, err := te.export(json)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertResultsContain(t, results,
+		fName+"_foo_meter_count 1",
+		fName+"_foo_meter_per_second{rate=\"(1m|5m|15m|mean)\"} 2",
+		fName+"_bar_timer_seconds_count 2",
+		fName+"_bar_timer_seconds_max 0.25",
+		fName+"_bar_timer_seconds{percentile=\"0\\.\\d+\"} 0.25",
+		fName+"_bar_timer_per_second{rate=\"(1m|5m|15m|mean)\"} 2")
+
+	te.exporter.rawUnits = true
+	results, err = te.export(json)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertResultsContain(t, results,
+		fName+"_foo_meter{rate=\"(1m|5m|15m|mean)\"} 120",
+		fName+"_bar_timer_count 2",
+		fName+"_bar_timer_max 250",
+		fName+"_bar_timer_rate{rate=\"(1m|5m|15m|mean)\"} 2")
+
+	assertResultsDoNotContain(t, results,
+		fName+"_bar_timer_seconds")
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jeffail/gabs"
+)
+
+// appFilter selects the apps of v2/apps whose metrics are exported. An app
+// is exported if it matches every include filter given and none of the
+// exclude filters.
+type appFilter struct {
+	includeID     *regexp.Regexp
+	excludeID     *regexp.Regexp
+	includeGroups []string
+	excludeGroups []string
+	includeLabels labelSelector
+	excludeLabels labelSelector
+}
+
+// newAppFilter returns the filter of the given settings, nil if they filter
+// nothing.
+func newAppFilter(s *settings) (*appFilter, error) {
+	f := &appFilter{}
+	var err error
+	if f.includeGroups, err = splitGroups(s.appIncludeGroups); err != nil {
+		return nil, fmt.Errorf("invalid marathon.app-include-groups: %v", err)
+	}
+	if f.excludeGroups, err = splitGroups(s.appExcludeGroups); err != nil {
+		return nil, fmt.Errorf("invalid marathon.app-exclude-groups: %v", err)
+	}
+	if f.includeID, err = compileAnchored(s.appInclude); err != nil {
+		return nil, fmt.Errorf("invalid marathon.app-include: %v", err)
+	}
+	if f.excludeID, err = compileAnchored(s.appExclude); err != nil {
+		return nil, fmt.Errorf("invalid marathon.app-exclude: %v", err)
+	}
+	if f.includeLabels, err = parseLabelSelector(s.appIncludeLabels); err != nil {
+		return nil, fmt.Errorf("invalid marathon.app-include-labels: %v", err)
+	}
+	if f.excludeLabels, err = parseLabelSelector(s.appExcludeLabels); err != nil {
+		return nil, fmt.Errorf("invalid marathon.app-exclude-labels: %v", err)
+	}
+
+	if f.includeID == nil && f.excludeID == nil && f.includeGroups == nil && f.excludeGroups == nil &&
+		f.includeLabels == nil && f.excludeLabels == nil {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// compileAnchored compiles a regular expression matching whole strings, nil
+// if expr is empty.
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// splitGroups returns the comma-separated groups of value, without their
+// trailing slashes.
+func splitGroups(value string) ([]string, error) {
+	var groups []string
+	for _, group := range strings.Split(value, ",") {
+		if group = strings.TrimSpace(group); group == "" {
+			continue
+		}
+		if !strings.HasPrefix(group, "/") {
+			return nil, fmt.Errorf("invalid group %q, expected an absolute path such as /ci", group)
+		}
+		groups = append(groups, strings.TrimRight(group, "/"))
+	}
+	return groups, nil
+}
+
+// inGroups reports whether the app id belongs to one of groups, directly or
+// through a subgroup.
+func inGroups(id string, groups []string) bool {
+	for _, group := range groups {
+		if strings.HasPrefix(id, group+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the metrics of the app with the given id and
+// labels are exported. A nil filter exports every app.
+func (f *appFilter) matches(id string, labels map[string]string) bool {
+	switch {
+	case f == nil:
+		return true
+	case f.includeID != nil && !f.includeID.MatchString(id):
+		return false
+	case f.includeGroups != nil && !inGroups(id, f.includeGroups):
+		return false
+	case f.includeLabels != nil && !f.includeLabels.matches(labels):
+		return false
+	case f.excludeID != nil && f.excludeID.MatchString(id):
+		return false
+	case inGroups(id, f.excludeGroups):
+		return false
+	case f.excludeLabels != nil && f.excludeLabels.matches(labels):
+		return false
+	}
+	return true
+}
+
+// metricSections are the types of the Dropwizard metrics of the metrics
+// endpoint, by the section holding them.
+var metricSections = map[string]string{
+	"counters":   "counter",
+	"gauges":     "gauge",
+	"histograms": "histogram",
+	"meters":     "meter",
+	"timers":     "timer",
+}
+
+// metricFilter selects the Dropwizard metrics of the metrics endpoint that
+// are exported, by key and by type. A metric is exported if it matches the
+// include filters given and none of the exclude filters.
+type metricFilter struct {
+	include      *regexp.Regexp
+	exclude      *regexp.Regexp
+	includeTypes map[string]bool
+	excludeTypes map[string]bool
+}
+
+// newMetricFilter returns the filter of the given settings, nil if they
+// filter nothing.
+func newMetricFilter(s *settings) (*metricFilter, error) {
+	f := &metricFilter{}
+	var err error
+	if f.include, err = compileAnchored(s.metricInclude); err != nil {
+		return nil, fmt.Errorf("invalid marathon.metric-include: %v", err)
+	}
+	if f.exclude, err = compileAnchored(s.metricExclude); err != nil {
+		return nil, fmt.Errorf("invalid marathon.metric-exclude: %v", err)
+	}
+	if f.includeTypes, err = parseMetricTypes(s.metricIncludeTypes); err != nil {
+		return nil, fmt.Errorf("invalid marathon.metric-include-types: %v", err)
+	}
+	if f.excludeTypes, err = parseMetricTypes(s.metricExcludeTypes); err != nil {
+		return nil, fmt.Errorf("invalid marathon.metric-exclude-types: %v", err)
+	}
+
+	if f.include == nil && f.exclude == nil && f.includeTypes == nil && f.excludeTypes == nil {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// parseMetricTypes returns the set of comma-separated metric types of value,
+// nil if there is none.
+func parseMetricTypes(value string) (map[string]bool, error) {
+	var types map[string]bool
+	for _, typ := range strings.Split(value, ",") {
+		if typ = strings.TrimSpace(typ); typ == "" {
+			continue
+		}
+		if !metricTypes[typ] {
+			return nil, fmt.Errorf("unknown metric type %q, expected counter, gauge, histogram, meter or timer", typ)
+		}
+		if types == nil {
+			types = make(map[string]bool)
+		}
+		types[typ] = true
+	}
+	return types, nil
+}
+
+// matches reports whether the Dropwizard metric key of type typ is exported.
+// A nil filter exports every metric.
+func (f *metricFilter) matches(typ, key string) bool {
+	switch {
+	case f == nil:
+		return true
+	case f.includeTypes != nil && !f.includeTypes[typ]:
+		return false
+	case f.include != nil && !f.include.MatchString(key):
+		return false
+	case f.excludeTypes[typ]:
+		return false
+	case f.exclude != nil && f.exclude.MatchString(key):
+		return false
+	}
+	return true
+}
+
+// filteredKeys returns in order the keys of the Dropwizard metrics of a
+// section of the metrics endpoint that are exported, counting the others.
+func (e *Exporter) filteredKeys(section string, elements map[string]*gabs.Container) []string {
+	keys := sortedKeys(elements)
+	if e.metricFilter == nil {
+		return keys
+	}
+
+	typ := metricSections[section]
+	kept := keys[:0]
+	for _, key := range keys {
+		if e.metricFilter.matches(typ, key) {
+			kept = append(kept, key)
+		}
+	}
+	if filtered := len(keys) - len(kept); filtered > 0 {
+		e.metricsFiltered.WithLabelValues(typ).Add(float64(filtered))
+	}
+	return kept
+}
+
+// appLabels returns the Marathon labels of a v2/apps app.
+func appLabels(app *gabs.Container) map[string]string {
+	children, _ := app.S("labels").ChildrenMap()
+	labels := make(map[string]string, len(children))
+	for key, child := range children {
+		if value, ok := child.Data().(string); ok {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// labelSelector selects the Marathon labels matching all its requirements,
+// in the syntax of Kubernetes equality-based selectors: key=value,
+// key!=value, key for an existing label and !key for a missing one, joined
+// by commas.
+type labelSelector []labelRequirement
+
+type labelRequirement struct {
+	key   string
+	value string
+	op    int
+}
+
+const (
+	labelEquals = iota
+	labelNotEquals
+	labelExists
+	labelNotExists
+)
+
+func parseLabelSelector(selector string) (labelSelector, error) {
+	if strings.TrimSpace(selector) == "" {
+		return nil, nil
+	}
+
+	var s labelSelector
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		var r labelRequirement
+		switch {
+		case strings.HasPrefix(part, "!"):
+			r.key, r.op = part[1:], labelNotExists
+		case strings.Contains(part, "!="):
+			parts := strings.SplitN(part, "!=", 2)
+			r.key, r.value, r.op = parts[0], parts[1], labelNotEquals
+		case strings.Contains(part, "="):
+			parts := strings.SplitN(strings.Replace(part, "==", "=", 1), "=", 2)
+			r.key, r.value, r.op = parts[0], parts[1], labelEquals
+		default:
+			r.key, r.op = part, labelExists
+		}
+		if r.key = strings.TrimSpace(r.key); r.key == "" || strings.ContainsAny(r.key, "!=") {
+			return nil, fmt.Errorf("invalid requirement %q, expected key=value, key!=value, key or !key", part)
+		}
+		r.value = strings.TrimSpace(r.value)
+		s = append(s, r)
+	}
+	return s, nil
+}
+
+func (s labelSelector) matches(labels map[string]string) bool {
+	for _, r := range s {
+		value, ok := labels[r.key]
+		switch {
+		case r.op == labelEquals && (!ok || value != r.value):
+			return false
+		case r.op == labelNotEquals && ok && value == r.value:
+			return false
+		case r.op == labelExists && !ok:
+			return false
+		case r.op == labelNotExists && ok:
+			return false
+		}
+	}
+	return true
+}
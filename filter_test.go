@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_label_selector(t *testing.T) {
+	labels := map[string]string{"team": "payments", "monitoring": "disabled"}
+	cases := map[string]bool{
+		"monitoring=disabled":         true,
+		"monitoring==disabled":        true,
+		"monitoring=enabled":          false,
+		"monitoring!=enabled":         true,
+		"owner!=ci":                   true,
+		"team":                        true,
+		"owner":                       false,
+		"!owner":                      true,
+		"!team":                       false,
+		"team=payments, monitoring":   true,
+		"team=payments,owner=someone": false,
+	}
+	for selector, expect := range cases {
+		s, err := parseLabelSelector(selector)
+		if err != nil {
+			t.Errorf("%s: %v", selector, err)
+			continue
+		}
+		if s.matches(labels) != expect {
+			t.Errorf("%s: expected the labels to match: %v", selector, expect)
+		}
+	}
+
+	for _, selector := range []string{"=disabled", "team=payments,", "!", "a!b=c"} {
+		if _, err := parseLabelSelector(selector); err == nil {
+			t.Errorf("%s: expected an error", selector)
+		}
+	}
+}
+
+func Test_app_filter(t *testing.T) {
+	s := testSettings(t,
+		"-marathon.app-exclude=.*-canary",
+		"-marathon.app-include-groups=/prod,/staging/",
+		"-marathon.app-exclude-groups=/prod/ci",
+		"-marathon.app-exclude-labels=monitoring=disabled")
+	cases := []struct {
+		id     string
+		labels map[string]string
+		expect bool
+	}{
+		{"/prod/web", nil, true},
+		{"/staging/api/worker", nil, true},
+		{"/production/web", nil, false},
+		{"/prod/web-canary", nil, false},
+		{"/prod/ci/build-42", nil, false},
+		{"/prod/batch", map[string]string{"monitoring": "disabled"}, false},
+		{"/prod/batch", map[string]string{"monitoring": "enabled"}, true},
+	}
+	for _, c := range cases {
+		if s.appFilter.matches(c.id, c.labels) != c.expect {
+			t.Errorf("%s %v: expected the app to be exported: %v", c.id, c.labels, c.expect)
+		}
+	}
+
+	if testSettings(t).appFilter != nil {
+		t.Errorf("expected no filter without filter flags")
+	}
+	for _, args := range [][]string{
+		{"-marathon.app-include=("},
+		{"-marathon.app-exclude-groups=ci"},
+		{"-marathon.app-include-labels=!"},
+	} {
+		if _, err := loadSettings("test", args, flag.ContinueOnError); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func Test_export_filtered_apps(t *testing.T) {
+	exporter := NewExporter(pathScraper{
+		"v2/info": testMarathonInfo,
+		"metrics": `{}`,
+		"v2/apps?embed=apps.taskStats": `{"apps": [
+			{"id": "/prod/web", "version": "1", "instances": 3, "tasksRunning": 3},
+			{"id": "/ci/build-42", "version": "1", "instances": 1, "tasksRunning": 1},
+			{"id": "/prod/batch", "version": "1", "instances": 1, "tasksRunning": 0,
+			 "labels": {"monitoring": "disabled"}}
+		]}`,
+	}, "marathon")
+	exporter.appFilter = testSettings(t,
+		"-marathon.app-exclude-groups=/ci",
+		"-marathon.app-exclude-labels=monitoring=disabled").appFilter
+
+	w := httptest.NewRecorder()
+	metricsHandler(exporter).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	results := w.Body.Bytes()
+
+	assertResultsContain(t, results,
+		`marathon_app_instances\{app="/prod/web",app_version="1"\} 3`,
+		`marathon_app_task_running\{app="/prod/web",app_version="1"\} 3`)
+	assertResultsDoNotContain(t, results, "/ci/build-42", "/prod/batch")
+}
+
+func Test_metric_filter(t *testing.T) {
+	s := testSettings(t,
+		`-marathon.metric-include=mesosphere\.marathon\..*`,
+		`-marathon.metric-exclude=.*\.jvm\..*`,
+		"-marathon.metric-exclude-types=histogram, timer")
+	cases := []struct {
+		typ    string
+		key    string
+		expect bool
+	}{
+		{"counter", "mesosphere.marathon.core.task.update", true},
+		{"gauge", "mesosphere.marathon.jvm.threads", false},
+		{"gauge", "org.eclipse.jetty.threads", false},
+		{"timer", "mesosphere.marathon.api.v2.AppsResource.index", false},
+		{"meter", "mesosphere.marathon.api.v2.AppsResource.index", true},
+	}
+	for _, c := range cases {
+		if s.metricFilter.matches(c.typ, c.key) != c.expect {
+			t.Errorf("%s %s: expected the metric to be exported: %v", c.typ, c.key, c.expect)
+		}
+	}
+
+	if testSettings(t).metricFilter != nil {
+		t.Errorf("expected no filter without filter flags")
+	}
+	for _, args := range [][]string{
+		{"-marathon.metric-exclude=("},
+		{"-marathon.metric-include-types=timers"},
+	} {
+		if _, err := loadSettings("test", args, flag.ContinueOnError); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func Test_export_filtered_metrics(t *testing.T) {
+	exporter := NewExporter(&testScraper{`{
+		"counters": {"foo.requests": {"count": 1}, "jvm.gc": {"count": 2}},
+		"gauges": {"foo.threads": {"value": 3}},
+		"timers": {"foo.latency": {"count": 4, "min": 0, "max": 1, "mean": 0.5,
+			"p50": 0.5, "p75": 0.5, "p95": 0.5, "p98": 0.5, "p99": 0.5, "p999": 0.5, "stddev": 0,
+			"m1_rate": 0, "m5_rate": 0, "m15_rate": 0, "mean_rate": 0,
+			"duration_units": "seconds", "rate_units": "calls/second"}}
+	}`}, "marathon")
+	exporter.metricFilter = testSettings(t,
+		`-marathon.metric-exclude=jvm\..*`,
+		"-marathon.metric-exclude-types=timer").metricFilter
+
+	w := httptest.NewRecorder()
+	metricsHandler(exporter).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	results := w.Body.Bytes()
+
+	assertResultsContain(t, results,
+		"marathon_foo_requests 1",
+		"marathon_foo_threads 3",
+		`marathon_exporter_metrics_filtered_total\{type="counter"\} 1`,
+		`marathon_exporter_metrics_filtered_total\{type="timer"\} 1`)
+	assertResultsDoNotContain(t, results, "marathon_jvm_gc", "marathon_foo_latency")
+}
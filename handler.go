@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// descRE extracts the name and help of a metric from its Desc. The vendored
+// client library keeps both unexported and has no registry that can be
+// instantiated per request, so metricsHandler gathers metrics itself.
+var descRE = regexp.MustCompile(`^Desc\{fqName: ("(?:[^"\\]|\\.)*"), help: ("(?:[^"\\]|\\.)*")`)
+
+// metricsHandler serves the metrics of the given collectors, independently
+// of the default registry.
+func metricsHandler(collectors ...prometheus.Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families, err := gather(collectors...)
+		if err != nil {
+			http.Error(w, "An error has occurred:\n\n"+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		format := expfmt.Negotiate(r.Header)
+		w.Header().Set("Content-Type", string(format))
+		encoder := expfmt.NewEncoder(w, format)
+		for _, family := range families {
+			if err := encoder.Encode(family); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func gather(collectors ...prometheus.Collector) ([]*dto.MetricFamily, error) {
+	metricCh := make(chan prometheus.Metric)
+	go func() {
+		for _, c := range collectors {
+			c.Collect(metricCh)
+		}
+		close(metricCh)
+	}()
+
+	// Drain metricCh in case of premature return
+	defer func() {
+		for range metricCh {
+		}
+	}()
+
+	byName := make(map[string]*dto.MetricFamily)
+	for metric := range metricCh {
+		name, help, err := describe(metric.Desc())
+		if err != nil {
+			return nil, err
+		}
+
+		dtoMetric := &dto.Metric{}
+		if err := metric.Write(dtoMetric); err != nil {
+			return nil, fmt.Errorf("error collecting metric %v: %s", metric.Desc(), err)
+		}
+
+		family, ok := byName[name]
+		if !ok {
+			family = &dto.MetricFamily{
+				Name: proto.String(name),
+				Help: proto.String(help),
+				Type: metricType(dtoMetric),
+			}
+			byName[name] = family
+		}
+		family.Metric = append(family.Metric, dtoMetric)
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	families := make([]*dto.MetricFamily, 0, len(names))
+	for _, name := range names {
+		families = append(families, byName[name])
+	}
+	return families, nil
+}
+
+func describe(desc *prometheus.Desc) (name, help string, err error) {
+	match := descRE.FindStringSubmatch(desc.String())
+	if match == nil {
+		return "", "", fmt.Errorf("invalid metric descriptor %v", desc)
+	}
+	if name, err = strconv.Unquote(match[1]); err != nil {
+		return
+	}
+	help, err = strconv.Unquote(match[2])
+	return
+}
+
+func metricType(m *dto.Metric) *dto.MetricType {
+	switch {
+	case m.Gauge != nil:
+		return dto.MetricType_GAUGE.Enum()
+	case m.Counter != nil:
+		return dto.MetricType_COUNTER.Enum()
+	case m.Summary != nil:
+		return dto.MetricType_SUMMARY.Enum()
+	case m.Histogram != nil:
+		return dto.MetricType_HISTOGRAM.Enum()
+	}
+	return dto.MetricType_UNTYPED.Enum()
+}
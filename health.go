@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultReadyStaleness = 5 * time.Minute
+
+// contacted records that Marathon answered the exporter.
+func (e *Exporter) contacted(t time.Time) {
+	e.contactMutex.Lock()
+	defer e.contactMutex.Unlock()
+	if t.After(e.lastContact) {
+		e.lastContact = t
+	}
+}
+
+// lastContacted returns when Marathon last answered the exporter, the zero
+// time if it never did.
+func (e *Exporter) lastContacted() time.Time {
+	e.contactMutex.Lock()
+	defer e.contactMutex.Unlock()
+	return e.lastContact
+}
+
+// healthyHandler answers as long as the exporter process is running.
+func healthyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("Healthy.\n"))
+}
+
+// readyHandler answers successfully if the exporter talked to Marathon
+// within staleness. It never contacts Marathon itself, so that health checks
+// stay cheap.
+func readyHandler(e *Exporter, staleness time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		last := e.lastContacted()
+		switch {
+		case last.IsZero():
+			http.Error(w, "Not ready: Marathon has not answered yet.", http.StatusServiceUnavailable)
+		case time.Since(last) > staleness:
+			msg := fmt.Sprintf("Not ready: Marathon last answered %v ago.", time.Since(last).Truncate(time.Second))
+			http.Error(w, msg, http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("Ready.\n"))
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_ready_handler(t *testing.T) {
+	exporter := NewExporter(&testScraper{`{}`}, "marathon")
+	handler := readyHandler(exporter, time.Minute)
+	status := func() int {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/-/ready", nil))
+		return w.Code
+	}
+
+	if s := status(); s != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 before Marathon answered, got %d", s)
+	}
+
+	exporter.contacted(time.Now().Add(-2 * time.Minute))
+	if s := status(); s != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 once the last answer is stale, got %d", s)
+	}
+
+	// A successful scrape makes the exporter ready
+	exporter.refresh()
+	if s := status(); s != http.StatusOK {
+		t.Errorf("expected status 200 after a scrape, got %d", s)
+	}
+}
+
+func Test_ready_handler_failing_marathon(t *testing.T) {
+	exporter := NewExporter(&failingScraper{}, "marathon")
+	handler := readyHandler(exporter, time.Minute)
+
+	exporter.refresh()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/-/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 while Marathon fails, got %d", w.Code)
+	}
+}
+
+func Test_healthy_handler(t *testing.T) {
+	w := httptest.NewRecorder()
+	healthyHandler(w, httptest.NewRequest("GET", "/-/healthy", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func Test_watch_marathon(t *testing.T) {
+	var failing int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) != 0 {
+			http.Error(w, "leader election", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(testMarathonInfo))
+	}))
+	defer server.Close()
+
+	ready := prometheus.NewGauge(prometheus.GaugeOpts{Name: "ready", Help: "ready"})
+	recheck := make(chan struct{})
+	connected := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchMarathon(ctx, currentSettings(testSettings(t, "-marathon.uri="+server.URL)), ready, recheck, func() {
+		connected <- struct{}{}
+	})
+
+	waitReady := func(expected float64) {
+		for deadline := time.Now().Add(5 * time.Second); gaugeValue(t, ready) != expected; {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected ready to be %v, got %v", expected, gaugeValue(t, ready))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitReady(1)
+	<-connected
+
+	// Losing Marathon makes the exporter not ready again
+	atomic.StoreInt32(&failing, 1)
+	recheck <- struct{}{}
+	waitReady(0)
+
+	atomic.StoreInt32(&failing, 0)
+	recheck <- struct{}{}
+	waitReady(1)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// seriesLimits caps the number of series exported from Marathon, to keep a
+// misbehaving app, say with thousands of versions, from flooding Prometheus.
+// Zero means no limit.
+type seriesLimits struct {
+	total    int
+	family   int
+	families familyLimits
+}
+
+func (l seriesLimits) familyLimit(name string) int {
+	if limit, ok := l.families[name]; ok {
+		return limit
+	}
+	return l.family
+}
+
+// familyLimits holds the series limits of individual metric families. It
+// implements flag.Value, accepting comma-separated family=limit pairs.
+type familyLimits map[string]int
+
+func (f familyLimits) String() string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%d", name, f[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f familyLimits) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid family limit %q, expected family=limit", pair)
+		}
+		limit, err := strconv.Atoi(parts[1])
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid limit %q for family %s", parts[1], parts[0])
+		}
+		f[parts[0]] = limit
+	}
+	return nil
+}
+
+// truncate drops the series beyond the limits and returns the number of
+// series dropped per family. Series are kept in the order of their label
+// values, so the same ones are exported on every scrape.
+func (s *seriesSet) truncate(limits seriesLimits) map[string]int {
+	dropped := make(map[string]int)
+	byFamily := make(map[string][]*seriesEntry)
+	for i := range s.entries {
+		if entry := &s.entries[i]; entry.family != "" {
+			byFamily[entry.family] = append(byFamily[entry.family], entry)
+		}
+	}
+
+	var kept []*seriesEntry
+	for name, entries := range byFamily {
+		if limit := limits.familyLimit(name); limit > 0 && len(entries) > limit {
+			sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+			for _, entry := range entries[limit:] {
+				entry.dropped = true
+			}
+			dropped[name] += len(entries) - limit
+			entries = entries[:limit]
+		}
+		kept = append(kept, entries...)
+	}
+
+	if limits.total > 0 && len(kept) > limits.total {
+		sort.Slice(kept, func(i, j int) bool {
+			if kept[i].family != kept[j].family {
+				return kept[i].family < kept[j].family
+			}
+			return kept[i].key < kept[j].key
+		})
+		for _, entry := range kept[limits.total:] {
+			entry.dropped = true
+			dropped[entry.family]++
+		}
+	}
+	return dropped
+}
+
+// limit truncates the series of a scrape to the limits of the exporter.
+func (e *Exporter) limit(series *seriesSet) {
+	dropped := series.truncate(e.limits)
+	families := make([]string, 0, len(dropped))
+	for family := range dropped {
+		families = append(families, family)
+	}
+	sort.Strings(families)
+
+	for _, family := range families {
+		e.seriesDropped.WithLabelValues(family).Add(float64(dropped[family]))
+		e.warnOnce("limit:"+family, "Dropped %d series of %s over the series limits\n", dropped[family], family)
+	}
+}
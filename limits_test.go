@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_family_limits_flag(t *testing.T) {
+	limits := familyLimits{}
+	if err := limits.Set("marathon_app_instances=2,marathon_foo=0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := limits.Set("marathon_bar=5"); err != nil {
+		t.Fatal(err)
+	}
+	if s := limits.String(); s != "marathon_app_instances=2,marathon_bar=5,marathon_foo=0" {
+		t.Errorf("unexpected limits %s", s)
+	}
+
+	for _, value := range []string{"marathon_foo", "=1", "marathon_foo=x", "marathon_foo=-1"} {
+		if err := (familyLimits{}).Set(value); err == nil {
+			t.Errorf("expected an error setting %q", value)
+		}
+	}
+}
+
+func scrapeLimited(t *testing.T, limits seriesLimits) []byte {
+	exporter := NewExporter(pathScraper{
+		"v2/apps?embed=apps.taskStats": `{"apps": [
+			{"id": "/foo", "version": "3", "instances": 1},
+			{"id": "/foo", "version": "1", "instances": 1},
+			{"id": "/foo", "version": "2", "instances": 1},
+			{"id": "/bar", "version": "1", "instances": 1}
+		]}`,
+		"v2/info": `{"version": "1.6.322"}`,
+		"metrics": `{"counters": {"foo": {"count": 1}, "bar": {"count": 2}}}`,
+	}, "marathon")
+	exporter.limits = limits
+	server := httptest.NewServer(metricsHandler(exporter))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func Test_family_series_limit(t *testing.T) {
+	results := scrapeLimited(t, seriesLimits{
+		family:   1,
+		families: familyLimits{"marathon_app_instances": 2},
+	})
+
+	assertResultsContain(t, results,
+		`marathon_app_instances\{app="/bar",app_version="1"\} 1`,
+		`marathon_app_instances\{app="/foo",app_version="1"\} 1`,
+		"marathon_bar 2",
+		"marathon_foo 1",
+		`marathon_exporter_series_dropped_total\{family="marathon_app_instances"\} 2`,
+		"marathon_up 1")
+	assertResultsDoNotContain(t, results,
+		`app_version="2"`,
+		`app_version="3"`,
+		`family="marathon_bar"`,
+		`family="marathon_foo"`)
+}
+
+func Test_total_series_limit(t *testing.T) {
+	results := scrapeLimited(t, seriesLimits{total: 3})
+
+	assertResultsContain(t, results,
+		`marathon_app_instances\{app="/bar",app_version="1"\} 1`,
+		`marathon_app_instances\{app="/foo",app_version="1"\} 1`,
+		`marathon_app_instances\{app="/foo",app_version="2"\} 1`,
+		`marathon_exporter_series_dropped_total\{family="marathon_app_instances"\} 1`,
+		`marathon_exporter_series_dropped_total\{family="marathon_bar"\} 1`,
+		`marathon_exporter_series_dropped_total\{family="marathon_foo"\} 1`)
+	assertResultsDoNotContain(t, results,
+		`app_version="3"`,
+		"marathon_bar 2",
+		"marathon_foo 1")
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
 	"flag"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/matt-deboer/go-marathon"
@@ -13,23 +16,12 @@ import (
 	"github.com/prometheus/common/log"
 )
 
-var (
-	listenAddress = flag.String(
-		"web.listen-address", ":9088",
-		"Address to listen on for web interface and telemetry.")
+// connectTimeout bounds the requests checking connectivity to Marathon.
+const connectTimeout = 10 * time.Second
 
-	metricsPath = flag.String(
-		"web.telemetry-path", "/metrics",
-		"Path under which to expose metrics.")
-
-	marathonUri = flag.String(
-		"marathon.uri", "http://marathon.mesos:8080",
-		"URI of Marathon")
-)
-
-func marathonConnect(uri *url.URL) error {
+func marathonConnect(uri *url.URL, client *http.Client) error {
 	config := marathon.NewDefaultConfig()
-	config.URL = uri.String()
+	config.URL = baseURL(uri)
 
 	if uri.User != nil {
 		if passwd, ok := uri.User.Password(); ok {
@@ -37,25 +29,19 @@ func marathonConnect(uri *url.URL) error {
 			config.HTTPBasicAuthUser = uri.User.Username()
 		}
 	}
-	config.HTTPClient = &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: 10 * time.Second,
-			}).Dial,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
-	}
+	// The Marathon client takes no context, its requests are bounded by
+	// the client instead.
+	bounded := *client
+	bounded.Timeout = connectTimeout
+	config.HTTPClient = &bounded
 
 	log.Debugln("Connecting to Marathon")
-	client, err := marathon.NewClient(config)
+	marathonClient, err := marathon.NewClient(config)
 	if err != nil {
 		return err
 	}
 
-	info, err := client.Info()
+	info, err := marathonClient.Info()
 	if err != nil {
 		return err
 	}
@@ -64,39 +50,218 @@ func marathonConnect(uri *url.URL) error {
 	return nil
 }
 
+// newExporter returns an Exporter scraping s with the given settings.
+func newExporter(s Scraper, settings *settings) *Exporter {
+	exporter := NewExporter(s, defaultNamespace)
+	exporter.configure(s, settings)
+	return exporter
+}
+
+// configure makes the exporter scrape s with the given settings. Scrapes in
+// progress complete with the former ones.
+func (e *Exporter) configure(s Scraper, settings *settings) {
+	e.configMutex.Lock()
+	defer e.configMutex.Unlock()
+	e.scraper = s
+	e.concurrency = settings.collectorConcurrency
+	e.timeout = settings.collectorTimeout
+	e.summaries = settings.summaries
+	e.rawUnits = settings.rawUnits
+	e.metricsFormat = settings.metricsFormat
+	e.versionInterval = settings.versionInterval
+	e.limits = settings.limits()
+	e.mapper = settings.mapper
+	e.appFilter = settings.appFilter
+	e.metricFilter = settings.metricFilter
+	e.collectorConfigs = settings.collectorConfigs
+
+	// The settings ignored by the Prometheus metrics of Marathon are warned
+	// about again, at once if that format is requested.
+	e.warnedMutex.Lock()
+	delete(e.warned, passthroughWarning)
+	e.warnedMutex.Unlock()
+	if e.metricsFormat == metricsFormatPrometheus {
+		e.warnPassthrough()
+	}
+
+	// Marathon may have been replaced, its version is detected again
+	e.versionMutex.Lock()
+	e.versionNext = time.Time{}
+	e.versionMutex.Unlock()
+}
+
 func main() {
-	flag.Parse()
-	uri, err := url.Parse(*marathonUri)
+	startup, err := loadSettings(os.Args[0], os.Args[1:], flag.ExitOnError)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := startup.applyLogging(nil); err != nil {
+		log.Fatal(err)
+	}
 
-	retryTimeout := time.Duration(10 * time.Second)
-	for {
-		err := marathonConnect(uri)
-		if err == nil {
-			break
-		}
+	// The listener is opened before anything else, so that health checks
+	// answer as early as possible.
+	log.Info("Starting Server: ", startup.listenAddress)
+	listener, err := net.Listen("tcp", startup.listenAddress)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		log.Debugf("Problem connecting to Marathon: %v", err)
-		log.Infof("Couldn't connect to Marathon! Trying again in %v", retryTimeout)
-		time.Sleep(retryTimeout)
+	ready := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: defaultNamespace,
+		Subsystem: "exporter",
+		Name:      "ready",
+		Help:      "Whether the last connectivity check to Marathon succeeded (1 for ready, 0 otherwise).",
+	})
+	prometheus.MustRegister(ready)
+
+	breaker := newCircuitBreaker(defaultNamespace, startup.breakerThreshold, startup.breakerCooldown)
+	prometheus.MustRegister(breaker)
+
+	var recorder *responseRecorder
+	if startup.enableDebug {
+		recorder = newResponseRecorder()
+	}
+	newScraper := func(s *settings) *scraper {
+		return &scraper{
+			uri:      s.uri,
+			client:   newHTTPClient(s.uri, s.proxy, s.tlsConfig),
+			retries:  s.retries,
+			backoff:  s.retryBackoff,
+			breaker:  breaker,
+			recorder: recorder,
+		}
 	}
 
-	exporter := NewExporter(&scraper{uri}, defaultNamespace)
+	exporter := newExporter(newScraper(startup), startup)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exporter.ctx = ctx
+
+	// Marathon may have moved on reload, its connectivity is checked again.
+	recheck := make(chan struct{}, 1)
+	reloader := newReloader(defaultNamespace, os.Args[0], os.Args[1:], startup, func(s *settings) {
+		breaker.configure(s.breakerThreshold, s.breakerCooldown)
+		exporter.configure(newScraper(s), s)
+		select {
+		case recheck <- struct{}{}:
+		default:
+		}
+	})
+	prometheus.MustRegister(reloader)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	if startup.scrapeInterval > 0 {
+		exporter.background = true
+		go func() {
+			exporter.loop(startup.scrapeInterval, done)
+			close(stopped)
+		}()
+	} else {
+		close(stopped)
+	}
 	prometheus.MustRegister(exporter)
 
-	http.Handle(*metricsPath, prometheus.Handler())
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte(`<html>
-             <head><title>Marathon Exporter</title></head>
-             <body>
-             <h1>Marathon Exporter</h1>
-             <p><a href='` + *metricsPath + `'>Metrics</a></p>
-             </body>
-             </html>`))
+	// Importing net/http/pprof registers its handlers on the default mux,
+	// they must only be served with -web.enable-debug.
+	mux := http.NewServeMux()
+	mux.Handle(startup.metricsPath, prometheus.Handler())
+	mux.Handle(startup.probePath, probeHandler(reloader.settings))
+	mux.HandleFunc("/-/healthy", healthyHandler)
+	mux.Handle("/-/ready", readyHandler(exporter, startup.readyStaleness))
+	if startup.enableLifecycle {
+		mux.HandleFunc("/-/reload", reloader.handler)
+	}
+	mux.Handle("/", statusHandler(exporter, reloader.settings))
+	if startup.enableDebug {
+		handleDebug(mux, recorder)
+	}
+
+	// Marathon being down is reported through the exported metrics, it must
+	// not keep the exporter itself from answering.
+	go watchMarathon(ctx, reloader.settings, ready, recheck, func() {
+		exporter.contacted(time.Now())
+		exporter.configMutex.RLock()
+		exporter.detectVersion()
+		exporter.configMutex.RUnlock()
 	})
 
-	log.Info("Starting Server: ", *listenAddress)
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	server := &http.Server{Handler: mux}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serveWeb(server, listener, startup.webConfigFile)
+	}()
+
+	for stopping := false; !stopping; {
+		select {
+		case err := <-errs:
+			log.Fatal(err)
+		case s := <-signals:
+			if s == syscall.SIGHUP {
+				log.Infoln("Received hangup, reloading settings")
+				reloader.reload()
+				continue
+			}
+			log.Infof("Received %v, shutting down", s)
+			stopping = true
+		}
+	}
+
+	// Scrapes in flight get until the shutdown timeout to complete, then
+	// their requests to Marathon are cancelled.
+	close(done)
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), reloader.settings().shutdownTimeout)
+	defer cancelShutdown()
+	go func() {
+		<-shutdownCtx.Done()
+		cancel()
+	}()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Errorf("Problem shutting down the web server: %v", err)
+	}
+	cancel()
+	<-stopped
+	log.Infoln("Exporter stopped")
+}
+
+// connectInterval is how often connectivity to Marathon is checked.
+const connectInterval = 10 * time.Second
+
+// watchMarathon checks connectivity to the Marathon of the current settings
+// every connectInterval, and at once when recheck receives, until ctx is
+// done. ready reflects the outcome of the last check, connected is called
+// after every successful one.
+func watchMarathon(ctx context.Context, current func() *settings, ready prometheus.Gauge, recheck <-chan struct{}, connected func()) {
+	wasReady := false
+	for {
+		s := current()
+		err := marathonConnect(s.uri, newHTTPClient(s.uri, s.proxy, s.tlsConfig))
+		switch {
+		case err == nil && !wasReady:
+			log.Infoln("Connected to Marathon, exporter is ready")
+		case err != nil && wasReady:
+			log.Warnf("Lost connectivity to Marathon, exporter is not ready: %v", err)
+		case err != nil:
+			log.Debugf("Problem connecting to Marathon: %v", err)
+			log.Infof("Couldn't connect to Marathon! Trying again in %v", connectInterval)
+		}
+
+		wasReady = err == nil
+		if wasReady {
+			ready.Set(1)
+			connected()
+		} else {
+			ready.Set(0)
+		}
+
+		select {
+		case <-time.After(connectInterval):
+		case <-recheck:
+		case <-ctx.Done():
+			return
+		}
+	}
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels are set by the exporter itself on Dropwizard metrics.
+var reservedLabels = map[string]bool{
+	"rate":       true,
+	"percentile": true,
+	"quantile":   true,
+}
+
+// metricMapping turns the Dropwizard metrics matching a glob or a regular
+// expression into a metric name and labels. Both may refer to the segments
+// captured by the match with $1, $2, ... A glob's * matches a single
+// dot-separated segment.
+type metricMapping struct {
+	Match     string            `yaml:"match"`
+	MatchType string            `yaml:"match_type"`
+	Name      string            `yaml:"name"`
+	Labels    map[string]string `yaml:"labels"`
+
+	regex      *regexp.Regexp
+	labelNames []string
+}
+
+type metricMapper struct {
+	Mappings []*metricMapping `yaml:"mappings"`
+}
+
+// dropwizardMetric is the name and labels a Dropwizard metric is exported with.
+type dropwizardMetric struct {
+	name   string
+	labels []string
+	values []string
+}
+
+// labelNames returns the labels of the metric followed by extra labels.
+func (m dropwizardMetric) labelNames(extra ...string) []string {
+	return append(append(make([]string, 0, len(m.labels)+len(extra)), m.labels...), extra...)
+}
+
+// labelValues returns the label values of the metric followed by extra values.
+func (m dropwizardMetric) labelValues(extra ...string) []string {
+	return append(append(make([]string, 0, len(m.values)+len(extra)), m.values...), extra...)
+}
+
+func loadMapping(path string) (*metricMapper, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := &metricMapper{}
+	if err := yaml.UnmarshalStrict(content, mapper); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if err := mapper.init(); err != nil {
+		return nil, fmt.Errorf("error in %s: %v", path, err)
+	}
+	return mapper, nil
+}
+
+func (m *metricMapper) init() error {
+	for i, mapping := range m.Mappings {
+		if err := mapping.init(); err != nil {
+			return fmt.Errorf("mapping %d (%q): %v", i+1, mapping.Match, err)
+		}
+	}
+	return nil
+}
+
+func (m *metricMapping) init() (err error) {
+	if m.Match == "" {
+		return errors.New("match is required")
+	}
+	if m.Name == "" {
+		return errors.New("name is required")
+	}
+
+	switch m.MatchType {
+	case "", "glob":
+		m.regex, err = regexp.Compile(globToRegex(m.Match))
+	case "regex":
+		m.regex, err = regexp.Compile("^(?:" + m.Match + ")$")
+	default:
+		return fmt.Errorf("unknown match_type %q, expected glob or regex", m.MatchType)
+	}
+	if err != nil {
+		return err
+	}
+
+	m.labelNames = make([]string, 0, len(m.Labels))
+	for label := range m.Labels {
+		if !labelNameRE.MatchString(label) || strings.HasPrefix(label, "__") {
+			return fmt.Errorf("invalid label name %q", label)
+		}
+		if reservedLabels[label] {
+			return fmt.Errorf("label %q is reserved by the exporter", label)
+		}
+		m.labelNames = append(m.labelNames, label)
+	}
+	sort.Strings(m.labelNames)
+	return nil
+}
+
+func globToRegex(glob string) string {
+	parts := strings.Split(glob, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return "^" + strings.Join(parts, "([^.]*)") + "$"
+}
+
+// mapMetric returns the name and labels of the first mapping matching key.
+func (m *metricMapper) mapMetric(key string) (dropwizardMetric, bool) {
+	for _, mapping := range m.Mappings {
+		match := mapping.regex.FindStringSubmatchIndex(key)
+		if match == nil {
+			continue
+		}
+
+		expand := func(template string) string {
+			return string(mapping.regex.ExpandString(nil, template, key, match))
+		}
+		metric := dropwizardMetric{
+			name:   renameMetric(expand(mapping.Name)),
+			labels: mapping.labelNames,
+			values: make([]string, len(mapping.labelNames)),
+		}
+		for i, label := range mapping.labelNames {
+			metric.values[i] = expand(mapping.Labels[label])
+		}
+		return metric, true
+	}
+	return dropwizardMetric{}, false
+}
+
+// dropwizardMetric returns the name and labels of the metric exported for a
+// Dropwizard key, falling back to the renamed key without labels.
+func (e *Exporter) dropwizardMetric(key string) dropwizardMetric {
+	if e.mapper != nil {
+		if metric, ok := e.mapper.mapMetric(key); ok {
+			return metric
+		}
+	}
+	if e.currentMetricNames() {
+		return dropwizardMetric{name: renameCurrentMetric(key)}
+	}
+	return dropwizardMetric{name: renameMetric(key)}
+}
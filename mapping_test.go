@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestMapper(t *testing.T, mappings ...*metricMapping) *metricMapper {
+	mapper := &metricMapper{Mappings: mappings}
+	if err := mapper.init(); err != nil {
+		t.Fatal(err)
+	}
+	return mapper
+}
+
+func Test_map_metric(t *testing.T) {
+	mapper := newTestMapper(t,
+		&metricMapping{
+			Match:  "mesosphere.marathon.api.v2.*.*",
+			Name:   "api_requests",
+			Labels: map[string]string{"resource": "$1", "method": "$2"},
+		},
+		&metricMapping{
+			Match:     `mesosphere\.marathon\.core\.(\w+)\..*`,
+			MatchType: "regex",
+			Name:      "core_${1}_events",
+		})
+
+	cases := []struct {
+		key    string
+		expect dropwizardMetric
+		ok     bool
+	}{
+		{
+			key: "mesosphere.marathon.api.v2.AppsResource.index",
+			expect: dropwizardMetric{
+				name:   "api_requests",
+				labels: []string{"method", "resource"},
+				values: []string{"index", "AppsResource"},
+			},
+			ok: true,
+		}, {
+			key: "mesosphere.marathon.core.launcher.offers",
+			expect: dropwizardMetric{
+				name:   "core_launcher_events",
+				labels: []string{},
+				values: []string{},
+			},
+			ok: true,
+		}, {
+			key: "mesosphere.marathon.api.v2.AppsResource.index.extra",
+		}, {
+			key: "jvm.threads.count",
+		},
+	}
+
+	for _, c := range cases {
+		metric, ok := mapper.mapMetric(c.key)
+		if ok != c.ok {
+			t.Errorf("expected %s to match: %v, got %v", c.key, c.ok, ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(metric, c.expect) {
+			t.Errorf("expected %s to map to %+v, got %+v", c.key, c.expect, metric)
+		}
+	}
+}
+
+func Test_mapping_validation(t *testing.T) {
+	cases := []*metricMapping{
+		{Name: "foo"},
+		{Match: "foo.*"},
+		{Match: "foo.*", Name: "foo", MatchType: "prefix"},
+		{Match: "foo(", Name: "foo", MatchType: "regex"},
+		{Match: "foo.*", Name: "foo", Labels: map[string]string{"bad-label": "$1"}},
+		{Match: "foo.*", Name: "foo", Labels: map[string]string{"rate": "$1"}},
+	}
+
+	for _, c := range cases {
+		if err := c.init(); err == nil {
+			t.Errorf("expected mapping %+v to be rejected", c)
+		}
+	}
+}
+
+func Test_export_mapped_metrics(t *testing.T) {
+
+	fName := getFunctionName()
+	te := newTestExporter(fName)
+	defer te.close()
+	te.exporter.mapper = newTestMapper(t, &metricMapping{
+		Match:  "api.*.*",
+		Name:   "api_requests",
+		Labels: map[string]string{"resource": "$1", "method": "$2"},
+	})
+
+	results, err := te.export(`{
+		"counters": {
+			"jvm.threads": {"count": 3}
+		},
+		"timers": {
+			"api.AppsResource.index": {"count":1,"p50":1,"p75":1,"p95":1,"p98":1,"p99":1,"p999":1,"max":1,"mean":1,"min":1,"stddev":1,"m1_rate":1,"m5_rate":1,"m15_rate":1,"mean_rate":1,"duration_units":"seconds","rate_units":"calls/second"},
+			"api.AppsResource.create": {"count":2,"p50":2,"p75":2,"p95":2,"p98":2,"p99":2,"p999":2,"max":2,"mean":2,"min":2,"stddev":2,"m1_rate":2,"m5_rate":2,"m15_rate":2,"mean_rate":2,"duration_units":"seconds","rate_units":"calls/second"}
+		}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertResultsContain(t, results,
+		fName+"_jvm_threads 3",
+		fName+`_api_requests_seconds_count\{method="index",resource="AppsResource"\} 1`,
+		fName+`_api_requests_seconds_count\{method="create",resource="AppsResource"\} 2`,
+		fName+`_api_requests_seconds\{method="index",percentile="0.99",resource="AppsResource"\} 1`,
+		fName+`_api_requests_per_second\{method="create",rate="1m",resource="AppsResource"\} 2`)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	metricsFormatAuto       = "auto"
+	metricsFormatJSON       = "json"
+	metricsFormatPrometheus = "prometheus"
+)
+
+// passthroughWarning is the warnOnce key of the settings ignored by the
+// Prometheus metrics of Marathon.
+const passthroughWarning = "passthrough"
+
+// passthroughIgnored returns the settings in use that only apply to the JSON
+// metrics of Marathon, and are ignored by the Prometheus metrics re-exposed
+// unchanged.
+func (e *Exporter) passthroughIgnored() []string {
+	var ignored []string
+	if e.mapper != nil && len(e.mapper.Mappings) > 0 {
+		ignored = append(ignored, "the mapping rules")
+	}
+	if e.summaries {
+		ignored = append(ignored, "-marathon.summaries")
+	}
+	if e.rawUnits {
+		ignored = append(ignored, "-marathon.raw-units")
+	}
+	if e.metricFilter != nil {
+		ignored = append(ignored, "the metric filters")
+	}
+	return ignored
+}
+
+// warnPassthrough warns once per configuration of the exporter about the
+// settings the Prometheus metrics of Marathon ignore.
+func (e *Exporter) warnPassthrough() {
+	if ignored := e.passthroughIgnored(); len(ignored) > 0 {
+		e.warnOnce(passthroughWarning, "The Prometheus metrics of Marathon are re-exposed unchanged, ignoring %s; use -marathon.metrics-format=json to apply them\n",
+			strings.Join(ignored, ", "))
+	}
+}
+
+// exportPrometheusMetrics re-exposes the metrics Marathon 1.7+ serves in
+// the Prometheus format, moving them into the exporter's namespace.
+func (e *Exporter) exportPrometheusMetrics(ctx context.Context, ch chan<- prometheus.Metric) error {
+	e.warnPassthrough()
+	content, err := e.scraper.Scrape(ctx, "metrics/prometheus")
+	if err != nil {
+		log.Debugf("Problem scraping metrics/prometheus endpoint: %v\n", err)
+		return err
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(content))
+	if err != nil {
+		log.Debugf("Problem parsing metrics/prometheus response: %v\n", err)
+		return err
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		e.exportFamily(families[name], ch)
+	}
+	return nil
+}
+
+func (e *Exporter) exportFamily(family *dto.MetricFamily, ch chan<- prometheus.Metric) {
+	// Marathon prefixes its own metrics with marathon_
+	name := strings.TrimPrefix(family.GetName(), "marathon_")
+	for _, metric := range family.Metric {
+		pairs := metric.GetLabel()
+		sort.Sort(labelPairs(pairs))
+		labels := make([]string, len(pairs))
+		values := make([]string, len(pairs))
+		for i, pair := range pairs {
+			labels[i], values[i] = pair.GetName(), pair.GetValue()
+		}
+
+		desc, new := e.Descs.Fetch(name, family.GetHelp(), labels...)
+		if new {
+			log.Infof("Added metric %q\n", name)
+		}
+
+		var m prometheus.Metric
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			m, _ = prometheus.NewConstMetric(desc, prometheus.CounterValue, metric.GetCounter().GetValue(), values...)
+		case dto.MetricType_GAUGE:
+			m, _ = prometheus.NewConstMetric(desc, prometheus.GaugeValue, metric.GetGauge().GetValue(), values...)
+		case dto.MetricType_UNTYPED:
+			m, _ = prometheus.NewConstMetric(desc, prometheus.UntypedValue, metric.GetUntyped().GetValue(), values...)
+		case dto.MetricType_SUMMARY:
+			summary := metric.GetSummary()
+			quantiles := make(map[float64]float64, len(summary.Quantile))
+			for _, q := range summary.Quantile {
+				quantiles[q.GetQuantile()] = q.GetValue()
+			}
+			m, _ = prometheus.NewConstSummary(desc, summary.GetSampleCount(), summary.GetSampleSum(), quantiles, values...)
+		case dto.MetricType_HISTOGRAM:
+			histogram := metric.GetHistogram()
+			buckets := make(map[float64]uint64, len(histogram.Bucket))
+			for _, b := range histogram.Bucket {
+				buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+			m, _ = prometheus.NewConstHistogram(desc, histogram.GetSampleCount(), histogram.GetSampleSum(), buckets, values...)
+		}
+
+		if m == nil {
+			log.Debugf("Bad metric! Could not re-expose %s%v\n", family.GetName(), pairs)
+			continue
+		}
+		ch <- m
+	}
+}
+
+type labelPairs []*dto.LabelPair
+
+func (p labelPairs) Len() int           { return len(p) }
+func (p labelPairs) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p labelPairs) Less(i, j int) bool { return p[i].GetName() < p[j].GetName() }
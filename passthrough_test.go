@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// pathScraper answers each Marathon path with its own response.
+type pathScraper map[string]string
+
+func (s pathScraper) Scrape(ctx context.Context, path string) ([]byte, error) {
+	content, ok := s[path]
+	if !ok {
+		return nil, &statusError{code: http.StatusNotFound, status: "404 Not Found"}
+	}
+	return []byte(content), nil
+}
+
+func scrapeWith(t *testing.T, s Scraper, format string) []byte {
+	exporter := NewExporter(s, "marathon")
+	exporter.metricsFormat = format
+	server := httptest.NewServer(metricsHandler(exporter))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+const currentMarathonMetrics = `# TYPE marathon_http_requests_active_gauge gauge
+marathon_http_requests_active_gauge 3
+# HELP marathon_http_responses_total Responses by status
+# TYPE marathon_http_responses_total counter
+marathon_http_responses_total{status="2xx"} 42
+marathon_http_responses_total{status="5xx"} 1
+# TYPE marathon_http_requests_duration_seconds summary
+marathon_http_requests_duration_seconds{quantile="0.5"} 0.01
+marathon_http_requests_duration_seconds{quantile="0.99"} 0.2
+marathon_http_requests_duration_seconds_sum 4.2
+marathon_http_requests_duration_seconds_count 300
+`
+
+func Test_export_prometheus_metrics(t *testing.T) {
+	results := scrapeWith(t, pathScraper{
+		"v2/apps":            `{"apps": []}`,
+		"v2/info":            `{"version": "1.7.50-a4b5c6d"}`,
+		"metrics/prometheus": currentMarathonMetrics,
+	}, metricsFormatAuto)
+
+	assertResultsContain(t, results,
+		"# TYPE marathon_http_requests_active_gauge gauge",
+		"marathon_http_requests_active_gauge 3",
+		"# HELP marathon_http_responses_total Responses by status",
+		`marathon_http_responses_total\{status="2xx"\} 42`,
+		`marathon_http_responses_total\{status="5xx"\} 1`,
+		"# TYPE marathon_http_requests_duration_seconds summary",
+		`marathon_http_requests_duration_seconds\{quantile="0.99"\} 0.2`,
+		"marathon_http_requests_duration_seconds_count 300",
+		"marathon_up 1")
+	assertResultsDoNotContain(t, results, "marathon_marathon_")
+}
+
+func Test_export_legacy_json_metrics(t *testing.T) {
+	results := scrapeWith(t, pathScraper{
+		"v2/apps": `{"apps": []}`,
+		"v2/info": `{"version": "1.6.322"}`,
+		"metrics": `{"version": "3.0.0", "counters": {"foo_count": {"count": 7}}}`,
+	}, metricsFormatAuto)
+
+	assertResultsContain(t, results,
+		"marathon_foo_count 7",
+		"marathon_up 1")
+}
+
+func Test_export_current_json_metrics(t *testing.T) {
+	results := scrapeWith(t, pathScraper{
+		"v2/apps": `{"apps": []}`,
+		"v2/info": `{"version": "1.7.50"}`,
+		"metrics": `{"gauges": {"marathon.apps.active.gauge": {"value": 12}}}`,
+	}, metricsFormatJSON)
+
+	assertResultsContain(t, results,
+		"marathon_apps_active 12",
+		"marathon_up 1")
+}
+
+func Test_export_prometheus_format_requires_current_marathon(t *testing.T) {
+	results := scrapeWith(t, pathScraper{
+		"v2/apps":            `{"apps": []}`,
+		"v2/info":            `{"version": "1.6.322"}`,
+		"metrics/prometheus": currentMarathonMetrics,
+	}, metricsFormatPrometheus)
+
+	assertResultsContain(t, results,
+		"marathon_exporter_last_scrape_error 1")
+	assertResultsDoNotContain(t, results, "marathon_http_responses_total")
+}
+
+func Test_passthrough_ignored_settings(t *testing.T) {
+	warned := func(e *Exporter) bool {
+		e.warnedMutex.Lock()
+		defer e.warnedMutex.Unlock()
+		return e.warned[passthroughWarning]
+	}
+	scraper := pathScraper{
+		"v2/apps":            `{"apps": []}`,
+		"v2/info":            `{"version": "1.7.50-a4b5c6d"}`,
+		"metrics/prometheus": currentMarathonMetrics,
+	}
+
+	exporter := newExporter(scraper, testSettings(t))
+	exporter.refresh()
+	if ignored := exporter.passthroughIgnored(); ignored != nil || warned(exporter) {
+		t.Errorf("expected no setting to be ignored, got %v", ignored)
+	}
+
+	// The auto format warns once Marathon is known to serve Prometheus metrics
+	exporter.configure(scraper, testSettings(t, "-marathon.summaries", "-marathon.metric-exclude-types=timer"))
+	if warned(exporter) {
+		t.Errorf("expected no warning before Marathon 1.7 is detected")
+	}
+	exporter.refresh()
+	if !warned(exporter) {
+		t.Errorf("expected a warning about the ignored settings")
+	}
+	expected := []string{"-marathon.summaries", "the metric filters"}
+	if ignored := exporter.passthroughIgnored(); !reflect.DeepEqual(ignored, expected) {
+		t.Errorf("expected %v to be ignored, got %v", expected, ignored)
+	}
+
+	// The Prometheus format warns on every reload
+	exporter.configure(scraper, testSettings(t, "-marathon.raw-units", "-marathon.metrics-format=prometheus"))
+	if !warned(exporter) {
+		t.Errorf("expected a warning as soon as the Prometheus format is configured")
+	}
+}
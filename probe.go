@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/log"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultProbeModule = "default"
+
+// probeModule holds the settings used to reach the Marathon targets probed
+// with it.
+type probeModule struct {
+	Username     string        `yaml:"username"`
+	Password     string        `yaml:"password"`
+	PasswordFile string        `yaml:"password_file"`
+	ProxyURL     string        `yaml:"proxy_url"`
+	Timeout      time.Duration `yaml:"timeout"`
+	TLS          tlsConfig     `yaml:"tls_config"`
+}
+
+type tlsConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+type probeConfig struct {
+	Modules map[string]probeModule `yaml:"modules"`
+}
+
+// loadProbeModules reads the probe modules from a YAML file. Without a file,
+// a single "default" module reaches targets the way the exporter reaches
+// its own Marathon.
+func loadProbeModules(path string) (map[string]probeModule, error) {
+	if path == "" {
+		return map[string]probeModule{
+			defaultProbeModule: {TLS: tlsConfig{InsecureSkipVerify: true}},
+		}, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := probeConfig{}
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	for name, module := range config.Modules {
+		module, err := module.resolve()
+		if err != nil {
+			return nil, fmt.Errorf("module %q: %v", name, err)
+		}
+		config.Modules[name] = module
+	}
+	return config.Modules, nil
+}
+
+// resolve checks the module and returns it with the password of its
+// password file.
+func (m probeModule) resolve() (probeModule, error) {
+	if m.PasswordFile != "" {
+		if m.Password != "" {
+			return m, errors.New("at most one of password and password_file can be set")
+		}
+		password, err := readSecret(m.PasswordFile)
+		if err != nil {
+			return m, err
+		}
+		m.Password = password
+	}
+	if _, err := m.tlsConfig(); err != nil {
+		return m, err
+	}
+	_, err := m.proxy()
+	return m, err
+}
+
+func (m probeModule) proxy() (*url.URL, error) {
+	if m.ProxyURL == "" {
+		return nil, nil
+	}
+	return url.Parse(m.ProxyURL)
+}
+
+func (m probeModule) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         m.TLS.ServerName,
+		InsecureSkipVerify: m.TLS.InsecureSkipVerify,
+	}
+
+	if m.TLS.CAFile != "" {
+		ca, err := ioutil.ReadFile(m.TLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", m.TLS.CAFile)
+		}
+	}
+
+	if m.TLS.CertFile != "" || m.TLS.KeyFile != "" {
+		if m.TLS.CertFile == "" || m.TLS.KeyFile == "" {
+			return nil, errors.New("both cert_file and key_file are required for client certificates")
+		}
+		cert, err := tls.LoadX509KeyPair(m.TLS.CertFile, m.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// exporter builds an Exporter scraping the Marathon at target.
+func (m probeModule) exporter(target string, settings *settings) (*Exporter, error) {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	uri, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	// Targets come from any client of the probe endpoint, they must not make
+	// the exporter dial local sockets.
+	if uri.Scheme != "http" && uri.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q, expected http or https", uri.Scheme)
+	}
+	if m.Username != "" {
+		uri.User = url.UserPassword(m.Username, m.Password)
+	}
+
+	proxy, err := m.proxy()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := m.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	exporter := newExporter(&scraper{
+		uri:     uri,
+		client:  newHTTPClient(uri, proxy, tlsConfig),
+		retries: settings.retries,
+		backoff: settings.retryBackoff,
+	}, settings)
+	if m.Timeout > 0 {
+		exporter.timeout = m.Timeout
+	}
+	return exporter, nil
+}
+
+// probeHandler scrapes the Marathon given by the target parameter with the
+// settings of the module parameter, so that a single exporter can serve
+// many Marathon clusters. Modules are taken from the current settings.
+func probeHandler(current func() *settings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings := current()
+		params := r.URL.Query()
+		target := params.Get("target")
+		if target == "" {
+			http.Error(w, "Target parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		name := params.Get("module")
+		if name == "" {
+			name = defaultProbeModule
+		}
+		module, ok := settings.modules[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unknown module %q", name), http.StatusBadRequest)
+			return
+		}
+
+		exporter, err := module.exporter(target, settings)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid target %q: %v", target, err), http.StatusBadRequest)
+			return
+		}
+
+		// Requests to the target are cancelled with the probe
+		exporter.ctx = r.Context()
+
+		log.Debugf("Probing %s with module %q\n", target, name)
+		metricsHandler(exporter).ServeHTTP(w, r)
+	}
+}
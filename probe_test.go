@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func newFakeMarathon(t *testing.T, user, password string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, _ := r.BasicAuth(); u != user || p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/metrics":
+			w.Write([]byte(`{"version": "3.0.0", "counters": {"foo_count": {"count": 7}}}`))
+		default:
+			w.Write([]byte(`{"apps": []}`))
+		}
+	}))
+}
+
+func probe(t *testing.T, modules map[string]probeModule, query url.Values) (int, []byte) {
+	settings := testSettings(t)
+	settings.modules = modules
+	server := httptest.NewServer(probeHandler(currentSettings(settings)))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode, body
+}
+
+func Test_probe_target(t *testing.T) {
+	marathon := newFakeMarathon(t, "", "")
+	defer marathon.Close()
+
+	modules, _ := loadProbeModules("")
+	status, body := probe(t, modules, url.Values{"target": {marathon.URL}})
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", status, body)
+	}
+
+	assertResultsContain(t, body,
+		"# TYPE marathon_foo_count counter",
+		"marathon_foo_count 7",
+		`marathon_metrics_version\{version="3.0.0"\} 1`,
+		"marathon_up 1")
+}
+
+func Test_probe_module_credentials(t *testing.T) {
+	marathon := newFakeMarathon(t, "user", "secret")
+	defer marathon.Close()
+
+	modules := map[string]probeModule{
+		"anonymous": {},
+		"auth":      {Username: "user", Password: "secret"},
+	}
+
+	_, body := probe(t, modules, url.Values{"target": {marathon.URL}, "module": {"anonymous"}})
+	assertResultsContain(t, body, "marathon_up 0")
+
+	_, body = probe(t, modules, url.Values{"target": {marathon.URL}, "module": {"auth"}})
+	assertResultsContain(t, body, "marathon_up 1", "marathon_foo_count 7")
+}
+
+func Test_probe_bad_requests(t *testing.T) {
+	modules, _ := loadProbeModules("")
+
+	if status, _ := probe(t, modules, url.Values{}); status != http.StatusBadRequest {
+		t.Errorf("expected status 400 without target, got %d", status)
+	}
+	if status, _ := probe(t, modules, url.Values{"target": {"marathon:8080"}, "module": {"unknown"}}); status != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown module, got %d", status)
+	}
+	for _, target := range []string{"unix:///var/run/docker.sock", "file:///etc/passwd", "ftp://marathon:21"} {
+		if status, _ := probe(t, modules, url.Values{"target": {target}}); status != http.StatusBadRequest {
+			t.Errorf("expected status 400 for target %s, got %d", target, status)
+		}
+	}
+}
+
+func Test_load_probe_modules(t *testing.T) {
+	file, err := ioutil.TempFile("", "modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString(`
+modules:
+  production:
+    username: prometheus
+    password: secret
+    timeout: 5s
+    tls_config:
+      insecure_skip_verify: true
+`)
+	file.Close()
+
+	modules, err := loadProbeModules(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, ok := modules["production"]
+	if !ok {
+		t.Fatal("expected a production module")
+	}
+	if module.Username != "prometheus" || module.Timeout.Seconds() != 5 || !module.TLS.InsecureSkipVerify {
+		t.Errorf("unexpected module %+v", module)
+	}
+
+	ioutil.WriteFile(file.Name(), []byte("modules:\n  production:\n    usename: typo\n"), 0644)
+	if _, err := loadProbeModules(file.Name()); err == nil {
+		t.Error("expected unknown fields to be rejected")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// restartFlags are the flags whose changes only apply once the exporter is
+// restarted: they shape the web server and the background loop, which keep
+// running across reloads.
+var restartFlags = []string{
+	"web.listen-address",
+	"web.telemetry-path",
+	"web.probe-path",
+	"web.config.file",
+	"web.ready-staleness",
+	"web.enable-debug",
+	"web.enable-lifecycle",
+	"marathon.scrape-interval",
+}
+
+// reloader loads the settings of the exporter again from its command line,
+// flags file and configuration file. New settings are only applied once
+// all of them are valid, otherwise the current ones are kept.
+type reloader struct {
+	name  string
+	args  []string
+	apply func(*settings)
+
+	// mutex serializes reloads, settingsMutex guards the current settings.
+	mutex         sync.Mutex
+	settingsMutex sync.RWMutex
+	current       *settings
+
+	success   prometheus.Gauge
+	timestamp prometheus.Gauge
+}
+
+func newReloader(namespace, name string, args []string, s *settings, apply func(*settings)) *reloader {
+	r := &reloader{
+		name:    name,
+		args:    args,
+		apply:   apply,
+		current: s,
+		success: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last reload of the settings succeeded (1 for success, 0 for failure).",
+		}),
+		timestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "Time of the last successful load of the settings, at startup or on reload.",
+		}),
+	}
+	r.success.Set(1)
+	r.timestamp.Set(float64(time.Now().UnixNano()) / 1e9)
+	return r
+}
+
+// settings returns the current settings.
+func (r *reloader) settings() *settings {
+	r.settingsMutex.RLock()
+	defer r.settingsMutex.RUnlock()
+	return r.current
+}
+
+func (r *reloader) reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s, err := loadSettings(r.name, r.args, flag.ContinueOnError)
+	if err != nil {
+		r.success.Set(0)
+		log.Errorf("Error reloading settings, keeping the current ones: %v\n", err)
+		return err
+	}
+
+	previous := r.settings()
+	if err := s.applyLogging(previous); err != nil {
+		log.Errorf("Problem applying the log settings: %v\n", err)
+	}
+	for _, name := range restartFlags {
+		if s.flags.Lookup(name).Value.String() != previous.flags.Lookup(name).Value.String() {
+			log.Warnf("Flag %s changed, restart the exporter to apply it\n", name)
+		}
+	}
+	r.apply(s)
+
+	r.settingsMutex.Lock()
+	r.current = s
+	r.settingsMutex.Unlock()
+	r.success.Set(1)
+	r.timestamp.Set(float64(time.Now().UnixNano()) / 1e9)
+	log.Infoln("Reloaded settings")
+	return nil
+}
+
+// handler reloads the settings on POST requests.
+func (r *reloader) handler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST requests reload the settings.", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.reload(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reload the settings: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("Reloaded.\n"))
+}
+
+// Describe implements prometheus.Collector.
+func (r *reloader) Describe(ch chan<- *prometheus.Desc) {
+	r.success.Describe(ch)
+	r.timestamp.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (r *reloader) Collect(ch chan<- prometheus.Metric) {
+	r.success.Collect(ch)
+	r.timestamp.Collect(ch)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	metric := &dto.Metric{}
+	if err := g.Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetGauge().GetValue()
+}
+
+func Test_reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	flagsFile := writeFile(t, dir, "flags", "-marathon.uri=http://marathon-a:8080\n")
+	args := []string{"-config.flags-file=" + flagsFile}
+	var applied *settings
+	r := newReloader("marathon", "test", args, testSettings(t, args...), func(s *settings) {
+		applied = s
+	})
+
+	writeFile(t, dir, "flags", "-marathon.uri=http://marathon-b:8080\n")
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if applied == nil || applied.uri.Host != "marathon-b:8080" {
+		t.Fatalf("expected the new URI to be applied, got %v", applied)
+	}
+	if r.settings() != applied {
+		t.Errorf("expected the applied settings to be current")
+	}
+	if gaugeValue(t, r.success) != 1 {
+		t.Errorf("expected the reload to be reported successful")
+	}
+
+	writeFile(t, dir, "flags", "-marathon.uri=http://marathon-c:8080\n-marathon.retries=many\n")
+	if err := r.reload(); err == nil {
+		t.Fatal("expected an error reloading invalid settings")
+	}
+	if applied.uri.Host != "marathon-b:8080" || r.settings() != applied {
+		t.Errorf("expected invalid settings not to be applied, got %v", applied.uri)
+	}
+	if gaugeValue(t, r.success) != 0 {
+		t.Errorf("expected the reload to be reported failed")
+	}
+}
+
+func Test_reload_handler(t *testing.T) {
+	r := newReloader("marathon", "test", nil, testSettings(t), func(*settings) {})
+
+	w := httptest.NewRecorder()
+	r.handler(w, httptest.NewRequest("GET", "/-/reload", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET to be refused, got status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.handler(w, httptest.NewRequest("POST", "/-/reload", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected POST to reload, got status %d: %s", w.Code, w.Body)
+	}
+}
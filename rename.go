@@ -26,3 +26,26 @@ func renameMetric(originalName string) (name string) {
 	name = strings.TrimRight(name, "_")
 	return
 }
+
+// metricTypes are the type segments ending the metric names of Marathon 1.7+.
+var metricTypes = map[string]bool{
+	"counter":   true,
+	"gauge":     true,
+	"histogram": true,
+	"meter":     true,
+	"timer":     true,
+}
+
+// renameCurrentMetric renames the metrics of Marathon 1.7+, named
+// marathon.<name>.<type>[.<unit>], to <name>[_<unit>].
+func renameCurrentMetric(originalName string) string {
+	segments := strings.Split(strings.TrimPrefix(originalName, "marathon."), ".")
+	n := len(segments)
+	switch {
+	case n > 1 && metricTypes[segments[n-1]]:
+		segments = segments[:n-1]
+	case n > 2 && metricTypes[segments[n-2]]:
+		segments = append(segments[:n-2], segments[n-1])
+	}
+	return renameMetric(strings.Join(segments, "."))
+}
@@ -66,3 +66,31 @@ func Test_rename_rate(t *testing.T) {
 		}
 	}
 }
+
+func Test_rename_current_metric(t *testing.T) {
+	cases := []struct {
+		name   string
+		expect string
+	}{
+		{
+			name:   "marathon.apps.active.gauge",
+			expect: "apps_active",
+		}, {
+			name:   "marathon.http.requests.duration.timer.seconds",
+			expect: "http_requests_duration_seconds",
+		}, {
+			name:   "marathon.http.responses.2xx.rate.meter",
+			expect: "http_responses_2xx_rate",
+		}, {
+			name:   "jvm.threads.count",
+			expect: "jvm_threads_count",
+		},
+	}
+
+	for _, c := range cases {
+		name := renameCurrentMetric(c.name)
+		if name != c.expect {
+			t.Errorf("expected metric named %s, got %s", c.expect, name)
+		}
+	}
+}
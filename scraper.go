@@ -1,46 +1,187 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+const (
+	defaultRetries      = 2
+	defaultRetryBackoff = 100 * time.Millisecond
 )
 
 type Scraper interface {
-	Scrape(path string) ([]byte, error)
+	Scrape(ctx context.Context, path string) ([]byte, error)
+}
+
+// statusError is returned when Marathon answers with an error status code.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected response from Marathon: %s", e.status)
 }
 
 type scraper struct {
-	uri *url.URL
+	uri    *url.URL
+	client *http.Client
+
+	// retries is the number of times a failed request is retried, waiting
+	// a random delay up to backoff, doubled on every attempt, in between.
+	retries int
+	backoff time.Duration
+	breaker *circuitBreaker
+
+	// recorder keeps the last responses of Marathon for debugging.
+	recorder *responseRecorder
 }
 
-func (s *scraper) Scrape(path string) ([]byte, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: 10 * time.Second,
-			}).Dial,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
+func (s *scraper) Scrape(ctx context.Context, path string) ([]byte, error) {
+	if s.breaker != nil && !s.breaker.allow() {
+		return nil, errCircuitOpen
+	}
+
+	body, err := s.scrapeWithRetries(ctx, path)
+	if s.breaker != nil {
+		s.breaker.record(err)
+	}
+	return body, err
+}
+
+func (s *scraper) scrapeWithRetries(ctx context.Context, path string) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		body, err := s.get(ctx, path)
+		if err == nil || attempt >= s.retries || !retryable(ctx, err) {
+			return body, err
+		}
+
+		delay := jitter(s.backoff << uint(attempt))
+		log.Debugf("Problem scraping %s, retrying in %v: %v\n", path, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *scraper) get(ctx context.Context, path string) (_ []byte, err error) {
+	var response *http.Response
+	var body []byte
+	if s.recorder != nil {
+		defer func(begin time.Time) {
+			s.recorder.record(path, begin, response, body, err)
+		}(time.Now())
+	}
+
+	client := s.client
+	if client == nil {
+		client = newHTTPClient(s.uri, nil, nil)
+	}
+
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/%s", baseURL(s.uri), path), nil)
+	if err != nil {
+		return nil, err
 	}
 
-	response, err := client.Get(fmt.Sprintf("%v/%s", s.uri, path))
+	response, err = client.Do(request.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
 
 	defer response.Body.Close()
-	body, err := ioutil.ReadAll(response.Body)
+	body, err = ioutil.ReadAll(response.Body)
 	if err != nil {
 		return nil, err
 	}
 
+	if response.StatusCode >= 400 {
+		return nil, &statusError{response.StatusCode, response.Status}
+	}
+
 	return body, err
 }
+
+// newHTTPClient returns a client reaching Marathon at uri. A unix:// uri dials
+// the socket at its path; otherwise requests go through proxy, or through
+// the proxy configured by HTTP_PROXY, HTTPS_PROXY and NO_PROXY when proxy is
+// nil. Proxies may use the http, https or socks5 schemes. Without a TLS
+// configuration, certificates presented by Marathon are not verified.
+func newHTTPClient(uri *url.URL, proxy *url.URL, tlsConfig *tls.Config) *http.Client {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		DialContext:     dialer.DialContext,
+		TLSClientConfig: tlsConfig,
+	}
+
+	if uri.Scheme == "unix" {
+		socket := uri.Path
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	} else if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	// Requests are bounded by the context of the collector or probe making
+	// them, a client timeout would override their configured timeouts.
+	return &http.Client{
+		Transport: transport,
+	}
+}
+
+// baseURL returns the URL Marathon's API paths are appended to. Requests over
+// a unix socket are plain HTTP to a placeholder host, keeping any credentials.
+func baseURL(uri *url.URL) string {
+	if uri.Scheme != "unix" {
+		return uri.String()
+	}
+
+	base := url.URL{
+		Scheme: "http",
+		User:   uri.User,
+		Host:   "unix",
+	}
+	return base.String()
+}
+
+// retryable reports whether a failed GET is worth retrying: transport errors
+// and the status codes Marathon answers with while it is overloaded or
+// electing a leader.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if e, ok := err.(*statusError); ok {
+		return e.code >= 500 || e.code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
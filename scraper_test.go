@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, statuses ...int) (*httptest.Server, *url.URL, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		if requests < len(statuses) {
+			status = statuses[requests]
+		}
+		requests++
+		w.WriteHeader(status)
+		w.Write([]byte(`{}`))
+	}))
+
+	uri, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, uri, &requests
+}
+
+func Test_scrape_retries_transient_errors(t *testing.T) {
+	server, uri, requests := newTestServer(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+	defer server.Close()
+
+	s := &scraper{uri: uri, retries: 2, backoff: time.Millisecond}
+	body, err := s.Scrape(context.Background(), "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{}` {
+		t.Errorf("unexpected body %q", body)
+	}
+	if *requests != 3 {
+		t.Errorf("expected 3 requests, got %d", *requests)
+	}
+}
+
+func Test_scrape_gives_up_after_retries(t *testing.T) {
+	server, uri, requests := newTestServer(t, 503, 503, 503, 503)
+	defer server.Close()
+
+	s := &scraper{uri: uri, retries: 2, backoff: time.Millisecond}
+	_, err := s.Scrape(context.Background(), "metrics")
+	if e, ok := err.(*statusError); !ok || e.code != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 status error, got %v", err)
+	}
+	if *requests != 3 {
+		t.Errorf("expected 3 requests, got %d", *requests)
+	}
+}
+
+func Test_scrape_does_not_retry_client_errors(t *testing.T) {
+	server, uri, requests := newTestServer(t, http.StatusUnauthorized)
+	defer server.Close()
+
+	s := &scraper{uri: uri, retries: 2, backoff: time.Millisecond}
+	if _, err := s.Scrape(context.Background(), "metrics"); err == nil {
+		t.Error("expected an error")
+	}
+	if *requests != 1 {
+		t.Errorf("expected a single request, got %d", *requests)
+	}
+}
+
+func Test_scrape_circuit_breaker(t *testing.T) {
+	server, uri, requests := newTestServer(t, 503, 503, 503, 503)
+	defer server.Close()
+
+	s := &scraper{uri: uri, breaker: newCircuitBreaker("marathon", 2, time.Minute)}
+	for i := 0; i < 4; i++ {
+		s.Scrape(context.Background(), "metrics")
+	}
+	if _, err := s.Scrape(context.Background(), "metrics"); err != errCircuitOpen {
+		t.Errorf("expected the circuit to be open, got %v", err)
+	}
+	if *requests != 2 {
+		t.Errorf("expected 2 requests before the circuit opened, got %d", *requests)
+	}
+}
+
+func Test_scrape_bounded_by_context(t *testing.T) {
+	uri := &url.URL{Scheme: "http", Host: "marathon:8080"}
+	if timeout := newHTTPClient(uri, nil, nil).Timeout; timeout != 0 {
+		t.Fatalf("expected requests to be bounded by their context only, the client times out after %v", timeout)
+	}
+
+	server, uri, _ := newTestServer(t)
+	defer server.Close()
+	s := &scraper{uri: uri, client: newHTTPClient(uri, nil, nil)}
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	if _, err := s.Scrape(ctx, "v2/apps"); err == nil {
+		t.Errorf("expected an error once the context is done")
+	}
+}
+
+func Test_scrape_unix_socket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marathon_exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "marathon.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &httptest.Server{
+		Listener: listener,
+		Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.URL.Path))
+		})},
+	}
+	server.Start()
+	defer server.Close()
+
+	uri := &url.URL{Scheme: "unix", Path: socket}
+	s := &scraper{uri: uri, client: newHTTPClient(uri, nil, nil)}
+	body, err := s.Scrape(context.Background(), "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "/metrics" {
+		t.Errorf("expected request for /metrics, got %q", body)
+	}
+}
+
+func Test_scrape_through_proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.String()))
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, err := url.Parse("http://marathon.mesos:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &scraper{uri: uri, client: newHTTPClient(uri, proxyURL, nil)}
+	body, err := s.Scrape(context.Background(), "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "http://marathon.mesos:8080/metrics" {
+		t.Errorf("expected the request to go through the proxy, got %q", body)
+	}
+}
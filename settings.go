@@ -0,0 +1,464 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/prometheus/common/log"
+)
+
+// settings are the options of the exporter, from its command line, flags
+// file and configuration file. They are loaded again on every reload, so
+// they are never modified once loaded.
+type settings struct {
+	configFile string
+	flagsFile  string
+
+	listenAddress    string
+	metricsPath      string
+	webConfigFile    string
+	readyStaleness   time.Duration
+	enableDebug      bool
+	enableLifecycle  bool
+	shutdownTimeout  time.Duration
+	probePath        string
+	probeModulesFile string
+
+	marathonURI          string
+	marathonUsername     string
+	marathonPassword     string
+	marathonPasswordFile string
+	marathonProxy        string
+	collectorConcurrency int
+	collectorTimeout     time.Duration
+	scrapeInterval       time.Duration
+	summaries            bool
+	rawUnits             bool
+	metricsFormat        string
+	versionInterval      time.Duration
+	seriesLimit          int
+	familySeriesLimit    int
+	familySeriesLimits   familyLimits
+	mappingFile          string
+	appInclude           string
+	appExclude           string
+	appIncludeGroups     string
+	appExcludeGroups     string
+	appIncludeLabels     string
+	appExcludeLabels     string
+	metricInclude        string
+	metricExclude        string
+	metricIncludeTypes   string
+	metricExcludeTypes   string
+	retries              int
+	retryBackoff         time.Duration
+	breakerThreshold     int
+	breakerCooldown      time.Duration
+
+	logLevel  string
+	logFormat string
+
+	// flags holds the flags the settings were parsed from, for display.
+	flags *flag.FlagSet
+
+	// The settings below are resolved from the ones above.
+	uri              *url.URL
+	proxy            *url.URL
+	tlsConfig        *tls.Config
+	modules          map[string]probeModule
+	mapper           *metricMapper
+	appFilter        *appFilter
+	metricFilter     *metricFilter
+	collectorConfigs map[string]collectorConfig
+}
+
+// flagSet returns the flags of the exporter, bound to s.
+func (s *settings) flagSet(name string, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	s.familySeriesLimits = familyLimits{}
+
+	fs := flag.NewFlagSet(name, errorHandling)
+	if errorHandling == flag.ContinueOnError {
+		// Errors are returned, and usage is only useful on the command line
+		fs.SetOutput(ioutil.Discard)
+	}
+	fs.StringVar(&s.configFile,
+		"config.file", "",
+		"YAML configuration file of the exporter. Flags given on the command line override its settings.")
+	fs.StringVar(&s.flagsFile,
+		"config.flags-file", "",
+		"File of flags, one -name=value per line, read at startup and on every reload. Flags given on the command line or in the environment override it.")
+
+	fs.StringVar(&s.listenAddress,
+		"web.listen-address", ":9088",
+		"Address to listen on for web interface and telemetry.")
+	fs.StringVar(&s.metricsPath,
+		"web.telemetry-path", "/metrics",
+		"Path under which to expose metrics.")
+	fs.StringVar(&s.webConfigFile,
+		"web.config.file", "",
+		"Path to a configuration file enabling TLS or basic authentication on the web endpoint, in the format of the Prometheus exporter toolkit.")
+	fs.DurationVar(&s.readyStaleness,
+		"web.ready-staleness", defaultReadyStaleness,
+		"How long after Marathon last answered the exporter stops reporting ready on /-/ready.")
+	fs.BoolVar(&s.enableDebug,
+		"web.enable-debug", false,
+		"Expose the last raw responses of Marathon at /debug/last-response and Go profiling at /debug/pprof/.")
+	fs.BoolVar(&s.enableLifecycle,
+		"web.enable-lifecycle", false,
+		"Reload the settings on POST requests to /-/reload.")
+	fs.DurationVar(&s.shutdownTimeout,
+		"web.shutdown-timeout", 10*time.Second,
+		"How long requests in flight are given to complete once the exporter is asked to stop.")
+	fs.StringVar(&s.probePath,
+		"web.probe-path", "/probe",
+		"Path under which to expose metrics of the Marathon given by the target parameter.")
+	fs.StringVar(&s.probeModulesFile,
+		"probe.modules-file", "",
+		"YAML file defining the modules available to probes.")
+
+	fs.StringVar(&s.marathonURI,
+		"marathon.uri", "http://marathon.mesos:8080",
+		"URI of Marathon")
+	fs.StringVar(&s.marathonUsername,
+		"marathon.username", "",
+		"Username of HTTP basic auth to Marathon, unless the URI holds credentials.")
+	fs.StringVar(&s.marathonPassword,
+		"marathon.password", "",
+		"Password of HTTP basic auth to Marathon. Prefer -marathon.password-file.")
+	fs.StringVar(&s.marathonPasswordFile,
+		"marathon.password-file", "",
+		"File holding the password of HTTP basic auth to Marathon, read at startup and on every reload.")
+	fs.StringVar(&s.marathonProxy,
+		"marathon.proxy-url", "",
+		"Proxy URL (http, https or socks5) to reach Marathon through. Defaults to HTTP_PROXY, HTTPS_PROXY and NO_PROXY.")
+	fs.IntVar(&s.collectorConcurrency,
+		"marathon.collector-concurrency", defaultCollectorConcurrency,
+		"Maximum number of Marathon endpoints scraped concurrently.")
+	fs.DurationVar(&s.collectorTimeout,
+		"marathon.collector-timeout", defaultCollectorTimeout,
+		"Timeout for scraping a single Marathon endpoint.")
+	fs.DurationVar(&s.scrapeInterval,
+		"marathon.scrape-interval", 0,
+		"Scrape Marathon in the background at this interval and serve the latest results (0 to scrape on every request).")
+	fs.BoolVar(&s.summaries,
+		"marathon.summaries", false,
+		"Export the histograms and timers of the JSON metrics of Marathon as Prometheus summaries.")
+	fs.BoolVar(&s.rawUnits,
+		"marathon.raw-units", false,
+		"Export the timers and meters of the JSON metrics of Marathon in their original units and names instead of seconds and rates per second.")
+	fs.StringVar(&s.metricsFormat,
+		"marathon.metrics-format", metricsFormatAuto,
+		"Format of the Marathon metrics to scrape: auto, json or prometheus. Auto re-exposes the Prometheus metrics of Marathon 1.7 and later, and parses the JSON metrics of older versions.")
+	fs.DurationVar(&s.versionInterval,
+		"marathon.version-interval", defaultVersionInterval,
+		"How often the version of Marathon, which selects how its endpoints are parsed, is detected again.")
+	fs.IntVar(&s.seriesLimit,
+		"marathon.series-limit", 0,
+		"Maximum number of series exported from Marathon per scrape (0 for no limit).")
+	fs.IntVar(&s.familySeriesLimit,
+		"marathon.family-series-limit", 0,
+		"Maximum number of series exported per metric family (0 for no limit).")
+	fs.Var(s.familySeriesLimits,
+		"marathon.family-series-limits",
+		"Comma-separated family=limit pairs overriding -marathon.family-series-limit for the given metric families, e.g. marathon_app_instances=500.")
+	fs.StringVar(&s.mappingFile,
+		"marathon.mapping-file", "",
+		"YAML file of rules mapping the names of the JSON metrics of Marathon to metric names and labels.")
+	fs.StringVar(&s.appInclude,
+		"marathon.app-include", "",
+		"Regular expression of the IDs of the apps to export, e.g. /prod/.* (empty for all apps).")
+	fs.StringVar(&s.appExclude,
+		"marathon.app-exclude", "",
+		"Regular expression of the IDs of the apps not to export.")
+	fs.StringVar(&s.appIncludeGroups,
+		"marathon.app-include-groups", "",
+		"Comma-separated groups whose apps, including those of their subgroups, are the only ones exported, e.g. /prod,/staging.")
+	fs.StringVar(&s.appExcludeGroups,
+		"marathon.app-exclude-groups", "",
+		"Comma-separated groups whose apps, including those of their subgroups, are not exported, e.g. /ci.")
+	fs.StringVar(&s.appIncludeLabels,
+		"marathon.app-include-labels", "",
+		"Selector of the Marathon labels of the apps to export, e.g. team=payments,monitoring.")
+	fs.StringVar(&s.appExcludeLabels,
+		"marathon.app-exclude-labels", "",
+		"Selector of the Marathon labels of the apps not to export, e.g. monitoring=disabled.")
+	fs.StringVar(&s.metricInclude,
+		"marathon.metric-include", "",
+		"Regular expression of the Dropwizard keys of the Marathon metrics to export, e.g. mesosphere\\.marathon\\.api\\..* (empty for all metrics).")
+	fs.StringVar(&s.metricExclude,
+		"marathon.metric-exclude", "",
+		"Regular expression of the Dropwizard keys of the Marathon metrics not to export.")
+	fs.StringVar(&s.metricIncludeTypes,
+		"marathon.metric-include-types", "",
+		"Comma-separated types of the Dropwizard metrics to export, among counter, gauge, histogram, meter and timer.")
+	fs.StringVar(&s.metricExcludeTypes,
+		"marathon.metric-exclude-types", "",
+		"Comma-separated types of the Dropwizard metrics not to export, e.g. histogram,timer.")
+	fs.IntVar(&s.retries,
+		"marathon.retries", defaultRetries,
+		"Number of times a failed request to Marathon is retried.")
+	fs.DurationVar(&s.retryBackoff,
+		"marathon.retry-backoff", defaultRetryBackoff,
+		"Maximum delay before the first retry, doubled on every subsequent retry.")
+	fs.IntVar(&s.breakerThreshold,
+		"marathon.breaker-threshold", 5,
+		"Consecutive failed requests after which requests to Marathon are paused (0 to disable).")
+	fs.DurationVar(&s.breakerCooldown,
+		"marathon.breaker-cooldown", 30*time.Second,
+		"How long requests to Marathon are paused once the circuit breaker opens.")
+
+	// The flags of the log package take effect as soon as they are parsed,
+	// they are applied once the settings are known to be valid instead
+	fs.StringVar(&s.logLevel,
+		"log.level", "info",
+		"Only log messages with the given severity or above. Valid levels: [debug, info, warn, error, fatal].")
+	fs.StringVar(&s.logFormat,
+		"log.format", "",
+		"If set use a syslog logger or JSON logging. Example: logger:syslog?appname=bob&local=7 or logger:stdout?json=true. Defaults to stderr.")
+
+	s.flags = fs
+	return fs
+}
+
+// loadSettings parses the command line args, preceded by the flags file and
+// completed by the environment, then by the configuration file, if any.
+func loadSettings(name string, args []string, errorHandling flag.ErrorHandling) (*settings, error) {
+	s := &settings{}
+	if err := parseFlags(s.flagSet(name, errorHandling), args); err != nil {
+		return nil, err
+	}
+	if err := applyEnv(s.flags, os.Environ()); err != nil {
+		return nil, err
+	}
+
+	// The flags file only sets the flags left unset by the command line and
+	// the environment.
+	if s.flagsFile != "" {
+		fileArgs, err := readFlagsFile(s.flagsFile)
+		if err != nil {
+			return nil, err
+		}
+		set := make(map[string]bool)
+		s.flags.Visit(func(f *flag.Flag) {
+			set[f.Name] = true
+		})
+		var unset []string
+		for _, arg := range fileArgs {
+			if !set[argName(arg)] {
+				unset = append(unset, arg)
+			}
+		}
+		if err := parseFlags(s.flags, unset); err != nil {
+			return nil, fmt.Errorf("error in %s: %v", s.flagsFile, err)
+		}
+	}
+
+	cfg := &config{}
+	if s.configFile != "" {
+		var err error
+		if cfg, err = loadConfig(s.configFile); err != nil {
+			return nil, err
+		}
+		if err := applyConfig(cfg, s.flags); err != nil {
+			return nil, fmt.Errorf("error in %s: %v", s.configFile, err)
+		}
+		s.collectorConfigs = cfg.collectorConfigs()
+	}
+
+	if err := s.resolve(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// envPrefix starts the names of the environment variables setting flags.
+const envPrefix = "MARATHON_EXPORTER_"
+
+// envName returns the environment variable setting the flag name, e.g.
+// MARATHON_EXPORTER_MARATHON_URI for marathon.uri.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// applyEnv sets the flags not given as arguments to the values of their
+// environment variables in env. Unknown variables with the prefix of the
+// exporter are warned about, as they are likely misspelled, but ignored:
+// the service links of Kubernetes define some, such as
+// MARATHON_EXPORTER_PORT.
+func applyEnv(fs *flag.FlagSet, env []string) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	names := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		names[envName(f.Name)] = f.Name
+	})
+
+	for _, pair := range env {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], envPrefix) {
+			continue
+		}
+		name, ok := names[parts[0]]
+		if !ok {
+			log.Warnf("Ignoring environment variable %s, which matches no flag\n", parts[0])
+			continue
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := fs.Set(name, parts[1]); err != nil {
+			return fmt.Errorf("environment variable %s: invalid value %q: %v", parts[0], parts[1], err)
+		}
+	}
+	return nil
+}
+
+// readFlagsFile returns the flags of a flags file. Empty lines and lines
+// starting with # are skipped.
+func readFlagsFile(path string) ([]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "-") {
+			return nil, fmt.Errorf("error in %s: line %d: expected a flag, got %q", path, i+1, line)
+		}
+		if argName(line) == "config.flags-file" {
+			return nil, fmt.Errorf("error in %s: line %d: config.flags-file cannot be set in the flags file", path, i+1)
+		}
+		args = append(args, line)
+	}
+	return args, nil
+}
+
+// argName returns the name of the flag set by arg, as in -name=value.
+func argName(arg string) string {
+	return strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)[0]
+}
+
+// resolve checks the settings and builds what the exporter needs from
+// them, with the settings of cfg that have no flag.
+func (s *settings) resolve(cfg *config) (err error) {
+	if _, err := logrus.ParseLevel(s.logLevel); err != nil {
+		return err
+	}
+	if s.logFormat != "" {
+		if u, err := url.Parse(s.logFormat); err != nil || u.Scheme != "logger" {
+			return fmt.Errorf("invalid log format %q", s.logFormat)
+		}
+	}
+	switch s.metricsFormat {
+	case metricsFormatAuto, metricsFormatJSON, metricsFormatPrometheus:
+	default:
+		return fmt.Errorf("unknown metrics format %q, expected auto, json or prometheus", s.metricsFormat)
+	}
+
+	if s.uri, err = url.Parse(s.marathonURI); err != nil {
+		return err
+	}
+	if s.marathonPassword != "" && s.marathonPasswordFile != "" {
+		return errors.New("at most one of marathon.password and marathon.password-file can be set")
+	}
+	password := s.marathonPassword
+	if s.marathonPasswordFile != "" {
+		if password, err = readSecret(s.marathonPasswordFile); err != nil {
+			return err
+		}
+	}
+	if s.uri.User == nil && s.marathonUsername != "" {
+		s.uri.User = url.UserPassword(s.marathonUsername, password)
+	}
+	if s.marathonProxy != "" {
+		if s.proxy, err = url.Parse(s.marathonProxy); err != nil {
+			return err
+		}
+	}
+	if cfg.Marathon.TLS != nil {
+		if s.tlsConfig, err = (probeModule{TLS: *cfg.Marathon.TLS}).tlsConfig(); err != nil {
+			return err
+		}
+	}
+
+	if s.appFilter, err = newAppFilter(s); err != nil {
+		return err
+	}
+	if s.metricFilter, err = newMetricFilter(s); err != nil {
+		return err
+	}
+
+	// Files given as flags replace the modules and mappings of the
+	// configuration file
+	s.modules = cfg.Modules
+	if s.probeModulesFile != "" || s.modules == nil {
+		if s.modules, err = loadProbeModules(s.probeModulesFile); err != nil {
+			return err
+		}
+	}
+	if s.mappingFile != "" {
+		if s.mapper, err = loadMapping(s.mappingFile); err != nil {
+			return err
+		}
+	} else if len(cfg.Mappings) > 0 {
+		s.mapper = &metricMapper{Mappings: cfg.Mappings}
+	}
+	return nil
+}
+
+// readSecret returns the content of a file holding a password or a token,
+// without its trailing newline.
+func readSecret(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// applyLogging sets the level and format of the logs. The format is only set
+// again if it differs from the one of the previous settings, if any, as
+// setting it may open a connection to syslog.
+func (s *settings) applyLogging(previous *settings) error {
+	if err := flag.CommandLine.Set("log.level", s.logLevel); err != nil {
+		return err
+	}
+	if s.logFormat != "" && (previous == nil || previous.logFormat != s.logFormat) {
+		return flag.CommandLine.Set("log.format", s.logFormat)
+	}
+	return nil
+}
+
+// limits returns the series limits of the settings.
+func (s *settings) limits() seriesLimits {
+	return seriesLimits{
+		total:    s.seriesLimit,
+		family:   s.familySeriesLimit,
+		families: s.familySeriesLimits,
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// snapshot holds the metrics gathered by one scrape of Marathon.
+type snapshot struct {
+	metrics []prometheus.Metric
+	time    time.Time
+}
+
+// flight is a scrape in progress, waited upon by every concurrent caller.
+type flight struct {
+	done     chan struct{}
+	snapshot *snapshot
+}
+
+// refresh scrapes Marathon and returns the resulting snapshot. Callers
+// arriving while a scrape is already running share its result instead of
+// starting another one.
+func (e *Exporter) refresh() *snapshot {
+	e.mutex.Lock()
+	if f := e.inflight; f != nil {
+		e.mutex.Unlock()
+		<-f.done
+		return f.snapshot
+	}
+	f := &flight{done: make(chan struct{})}
+	e.inflight = f
+	e.mutex.Unlock()
+
+	f.snapshot = e.takeSnapshot()
+
+	e.mutex.Lock()
+	e.inflight = nil
+	e.last = f.snapshot
+	e.mutex.Unlock()
+	close(f.done)
+	return f.snapshot
+}
+
+// latest returns the most recent snapshot, scraping Marathon if there is none yet.
+func (e *Exporter) latest() *snapshot {
+	e.mutex.Lock()
+	last := e.last
+	e.mutex.Unlock()
+
+	if last == nil {
+		return e.refresh()
+	}
+	return last
+}
+
+func (e *Exporter) takeSnapshot() *snapshot {
+	metricCh := make(chan prometheus.Metric)
+	doneCh := make(chan struct{})
+	s := &snapshot{}
+
+	go func() {
+		for m := range metricCh {
+			s.metrics = append(s.metrics, m)
+		}
+		close(doneCh)
+	}()
+
+	e.scrape(metricCh)
+	metricCh <- e.duration
+	metricCh <- e.totalScrapes
+	metricCh <- e.totalErrors
+	metricCh <- e.scrapeError
+	metricCh <- e.up
+	metricCh <- e.unsupportedVersion
+	metricCh <- e.metricCollisions
+	e.collectorDuration.Collect(metricCh)
+	e.collectorSuccess.Collect(metricCh)
+	e.seriesDropped.Collect(metricCh)
+	e.metricsFiltered.Collect(metricCh)
+	close(metricCh)
+	<-doneCh
+
+	s.time = time.Now()
+	return s
+}
+
+// loop scrapes Marathon every interval until done is closed, so that Collect
+// can serve the latest snapshot without querying Marathon itself.
+func (e *Exporter) loop(interval time.Duration, done <-chan struct{}) {
+	log.Infof("Scraping Marathon every %v in the background\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		e.refresh()
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type countingScraper struct {
+	delay    time.Duration
+	requests int32
+}
+
+func (s *countingScraper) Scrape(ctx context.Context, path string) ([]byte, error) {
+	atomic.AddInt32(&s.requests, 1)
+	time.Sleep(s.delay)
+	return []byte(`{}`), nil
+}
+
+func drain(e *Exporter) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		e.Collect(ch)
+		close(ch)
+	}()
+
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
+
+func Test_refresh_deduplicates_concurrent_scrapes(t *testing.T) {
+	s := &countingScraper{delay: 20 * time.Millisecond}
+	e := NewExporter(s, "marathon")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			drain(e)
+		}()
+	}
+	wg.Wait()
+
+	// One scrape hits the apps, info and metrics endpoints
+	if n := atomic.LoadInt32(&s.requests); n != 3 {
+		t.Errorf("expected a single shared scrape (3 requests), got %d requests", n)
+	}
+}
+
+func Test_background_serves_snapshot(t *testing.T) {
+	s := &countingScraper{}
+	e := NewExporter(s, "marathon")
+	e.background = true
+
+	done := make(chan struct{})
+	defer close(done)
+	go e.loop(time.Hour, done)
+
+	for i := 0; i < 3; i++ {
+		if drain(e) == 0 {
+			t.Fatal("expected metrics from the snapshot")
+		}
+	}
+
+	if n := atomic.LoadInt32(&s.requests); n != 3 {
+		t.Errorf("expected Collect to serve the snapshot (3 requests), got %d requests", n)
+	}
+}
+
+func Test_describe_does_not_scrape(t *testing.T) {
+	scraper := &countingScraper{}
+	exporter := NewExporter(scraper, "marathon")
+
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		exporter.Describe(ch)
+		close(ch)
+	}()
+	descs := 0
+	for range ch {
+		descs++
+	}
+
+	if descs == 0 {
+		t.Errorf("expected the metrics of the exporter to be described")
+	}
+	if requests := atomic.LoadInt32(&scraper.requests); requests != 0 {
+		t.Errorf("expected Describe not to scrape Marathon, made %d requests", requests)
+	}
+}
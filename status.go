@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/log"
+)
+
+// scrapeStatus is the outcome of the last scrape of Marathon.
+type scrapeStatus struct {
+	collectors    []collectorStatus
+	lastError     string
+	lastErrorTime time.Time
+	families      map[string]int
+}
+
+type collectorStatus struct {
+	Name     string
+	Time     time.Time
+	Duration time.Duration
+	Error    string
+}
+
+type familyStatus struct {
+	Name   string
+	Series int
+}
+
+type flagStatus struct {
+	Name  string
+	Value string
+}
+
+// recordResults keeps the outcome of the collectors of a scrape.
+func (e *Exporter) recordResults(results []collectorResult) {
+	now := time.Now()
+	e.statusMutex.Lock()
+	defer e.statusMutex.Unlock()
+
+	e.status.collectors = make([]collectorStatus, len(results))
+	for i, result := range results {
+		e.status.collectors[i] = collectorStatus{
+			Name:     result.name,
+			Time:     now,
+			Duration: result.duration,
+		}
+		if result.err != nil {
+			e.status.collectors[i].Error = result.err.Error()
+			e.status.lastError, e.status.lastErrorTime = result.err.Error(), now
+		}
+	}
+}
+
+// recordSeries keeps the number of series exported per family by a scrape.
+func (e *Exporter) recordSeries(counts map[string]int) {
+	e.statusMutex.Lock()
+	defer e.statusMutex.Unlock()
+	e.status.families = counts
+}
+
+// statusPage is the data of the status page.
+type statusPage struct {
+	MetricsPath   string
+	Target        string
+	ProbePath     string
+	ProbeModules  []string
+	Version       string
+	Leader        string
+	Collectors    []collectorStatus
+	LastError     string
+	LastErrorTime time.Time
+	Families      []familyStatus
+	Flags         []flagStatus
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<html>
+<head><title>Marathon Exporter</title></head>
+<body>
+<h1>Marathon Exporter</h1>
+<p><a href="{{.MetricsPath}}">Metrics</a></p>
+
+<h2>Marathon</h2>
+<table>
+<tr><th align="left">Target</th><td>{{.Target}}</td></tr>
+<tr><th align="left">Version</th><td>{{if .Version}}{{.Version}}{{else}}unknown{{end}}</td></tr>
+<tr><th align="left">Leader</th><td>{{if .Leader}}{{.Leader}}{{else}}unknown{{end}}</td></tr>
+<tr><th align="left">Probes</th><td>any target given to <code>{{.ProbePath}}</code>, with modules {{range $i, $m := .ProbeModules}}{{if $i}}, {{end}}<code>{{$m}}</code>{{end}}</td></tr>
+</table>
+
+<h2>Last scrape</h2>
+{{if .Collectors}}<table>
+<tr><th align="left">Collector</th><th align="left">Time</th><th align="left">Duration</th><th align="left">Result</th></tr>
+{{range .Collectors}}<tr><td>{{.Name}}</td><td>{{.Time.Format "2006-01-02 15:04:05 MST"}}</td><td>{{.Duration}}</td><td>{{if .Error}}{{.Error}}{{else}}success{{end}}</td></tr>
+{{end}}</table>{{else}}<p>Marathon has not been scraped yet.</p>{{end}}
+<p>Last error: {{if .LastError}}{{.LastError}} ({{.LastErrorTime.Format "2006-01-02 15:04:05 MST"}}){{else}}none{{end}}</p>
+
+<h2>Series per family</h2>
+{{if .Families}}<table>
+{{range .Families}}<tr><td>{{.Name}}</td><td align="right">{{.Series}}</td></tr>
+{{end}}</table>{{else}}<p>No series exported yet.</p>{{end}}
+
+<h2>Configuration</h2>
+<table>
+{{range .Flags}}<tr><th align="left">{{.Name}}</th><td><code>{{.Value}}</code></td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// statusHandler serves a page showing the state of the exporter. Like the
+// health checks, it never contacts Marathon.
+func statusHandler(e *Exporter, current func() *settings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The page is served at / only, not for every unknown path such as
+		// /-/reload without -web.enable-lifecycle
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		settings := current()
+		page := statusPage{
+			MetricsPath: settings.metricsPath,
+			Target:      redactURL(settings.uri.String()),
+			ProbePath:   settings.probePath,
+			Flags:       activeFlags(settings.flags),
+		}
+		for name := range settings.modules {
+			page.ProbeModules = append(page.ProbeModules, name)
+		}
+		sort.Strings(page.ProbeModules)
+
+		e.versionMutex.Lock()
+		if e.versionKnown {
+			page.Version = e.version.String()
+		}
+		page.Leader = e.leader
+		e.versionMutex.Unlock()
+
+		e.statusMutex.Lock()
+		page.Collectors = e.status.collectors
+		page.LastError, page.LastErrorTime = e.status.lastError, e.status.lastErrorTime
+		for name, series := range e.status.families {
+			page.Families = append(page.Families, familyStatus{Name: name, Series: series})
+		}
+		e.statusMutex.Unlock()
+		sort.Slice(page.Families, func(i, j int) bool { return page.Families[i].Name < page.Families[j].Name })
+
+		if err := statusTemplate.Execute(w, page); err != nil {
+			log.Errorf("Problem rendering the status page: %v\n", err)
+		}
+	}
+}
+
+// secretFlags are the flags whose values are never shown.
+var secretFlags = map[string]bool{
+	"marathon.password": true,
+}
+
+// activeFlags returns the value of every flag of fs, with credentials redacted.
+func activeFlags(fs *flag.FlagSet) []flagStatus {
+	var flags []flagStatus
+	fs.VisitAll(func(f *flag.Flag) {
+		value := redactURL(f.Value.String())
+		if secretFlags[f.Name] && value != "" {
+			value = "xxxxx"
+		}
+		flags = append(flags, flagStatus{Name: f.Name, Value: value})
+	})
+	return flags
+}
+
+// redactURL hides the password of a URL. Other values are returned as is.
+func redactURL(value string) string {
+	if !strings.Contains(value, "@") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
@@ -0,0 +1,53 @@
+package main
+
+import "strings"
+
+// durationScales converts Dropwizard duration units to seconds.
+var durationScales = map[string]float64{
+	"nanoseconds":  1e-9,
+	"microseconds": 1e-6,
+	"milliseconds": 1e-3,
+	"seconds":      1,
+	"minutes":      60,
+	"hours":        3600,
+	"days":         86400,
+}
+
+// durationScaleOf returns the factor converting durations expressed in unit,
+// such as "milliseconds", to seconds.
+func durationScaleOf(unit string) (float64, bool) {
+	scale, ok := durationScales[strings.ToLower(unit)]
+	return scale, ok
+}
+
+// rateScaleOf returns the factor converting rates expressed in units, such as
+// "calls/minute", to a rate per second.
+func rateScaleOf(units string) (float64, bool) {
+	parts := strings.Split(units, "/")
+	if len(parts) != 2 {
+		return 0, false
+	}
+
+	unit := strings.ToLower(parts[1])
+	if !strings.HasSuffix(unit, "s") {
+		unit += "s"
+	}
+	scale, ok := durationScales[unit]
+	if !ok {
+		return 0, false
+	}
+	return 1 / scale, true
+}
+
+// perSecond rewrites rate units such as "calls/minute" to "calls/second".
+func perSecond(units string) string {
+	return strings.Split(units, "/")[0] + "/second"
+}
+
+// withSuffix appends a base unit suffix to name, unless it already ends with it.
+func withSuffix(name, suffix string) string {
+	if strings.HasSuffix(name, "_"+suffix) {
+		return name
+	}
+	return name + "_" + suffix
+}
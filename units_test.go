@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func Test_duration_scale(t *testing.T) {
+	cases := map[string]float64{
+		"nanoseconds":  1e-9,
+		"milliseconds": 1e-3,
+		"seconds":      1,
+		"MINUTES":      60,
+	}
+
+	for unit, expect := range cases {
+		if scale, ok := durationScaleOf(unit); !ok || scale != expect {
+			t.Errorf("expected scale %v for %s, got %v", expect, unit, scale)
+		}
+	}
+	if _, ok := durationScaleOf("foos"); ok {
+		t.Error("expected unknown units not to be converted")
+	}
+}
+
+func Test_rate_scale(t *testing.T) {
+	cases := map[string]float64{
+		"calls/second":       1,
+		"events/minute":      1.0 / 60,
+		"calls/hour":         1.0 / 3600,
+		"calls/milliseconds": 1000,
+	}
+
+	for units, expect := range cases {
+		if scale, ok := rateScaleOf(units); !ok || scale != expect {
+			t.Errorf("expected scale %v for %s, got %v", expect, units, scale)
+		}
+	}
+	for _, units := range []string{"foos/bar", "calls", ""} {
+		if _, ok := rateScaleOf(units); ok {
+			t.Errorf("expected %q not to be converted", units)
+		}
+	}
+}
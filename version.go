@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jeffail/gabs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	// defaultVersionInterval is how long a detected Marathon version is
+	// trusted before v2/info is queried again.
+	defaultVersionInterval = time.Minute
+
+	// versionRetryInterval is how soon v2/info is queried again when
+	// Marathon did not answer it.
+	versionRetryInterval = 5 * time.Second
+
+	// versionTimeout bounds the detection of the version, so that it does
+	// not use up the timeout of the collector needing it.
+	versionTimeout = 5 * time.Second
+)
+
+// marathonParser parses the apps and metrics of the Marathon versions since
+// a given one.
+type marathonParser struct {
+	since   marathonVersion
+	apps    func(e *Exporter, ctx context.Context, ch chan<- prometheus.Metric) error
+	metrics func(e *Exporter, ctx context.Context, ch chan<- prometheus.Metric) error
+}
+
+// minSupportedVersion is the oldest Marathon the exporter parses the
+// endpoints of. Marathon 1.4 and older report metrics in other layouts.
+var minSupportedVersion = marathonVersion{1, 5, 0}
+
+// marathonParsers are ordered from the newest Marathon to the oldest one
+// supported.
+var marathonParsers = []marathonParser{
+	{
+		since:   marathonVersion{1, 7, 0},
+		apps:    (*Exporter).exportV2Apps,
+		metrics: (*Exporter).exportCurrentMetrics,
+	}, {
+		since:   minSupportedVersion,
+		apps:    (*Exporter).exportV2Apps,
+		metrics: (*Exporter).exportLegacyMetrics,
+	},
+}
+
+// fallbackParser is used when the version of Marathon cannot be detected.
+var fallbackParser = marathonParser{
+	apps:    (*Exporter).exportV2Apps,
+	metrics: (*Exporter).exportUnknownMetrics,
+}
+
+// supportedVersion reports whether the exporter parses the endpoints of
+// Marathon v.
+func supportedVersion(v marathonVersion) bool {
+	return v.since(minSupportedVersion)
+}
+
+type unsupportedVersionError struct {
+	version marathonVersion
+}
+
+func (e *unsupportedVersionError) Error() string {
+	return fmt.Sprintf("Marathon %v is not supported, %d.%d or later is required",
+		e.version, minSupportedVersion.major, minSupportedVersion.minor)
+}
+
+type marathonVersion struct {
+	major, minor, patch int
+}
+
+func parseVersion(version string) (v marathonVersion, err error) {
+	// Drop pre-release and build suffixes, as in 1.7.50-a4b5c6d or 1.6.322+dev
+	if i := strings.IndexAny(version, "-+ "); i >= 0 {
+		version = version[:i]
+	}
+
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	numbers := []*int{&v.major, &v.minor, &v.patch}
+	if len(parts) < 2 || len(parts) > len(numbers) {
+		return v, fmt.Errorf("invalid Marathon version %q", version)
+	}
+	for i, part := range parts {
+		if *numbers[i], err = strconv.Atoi(part); err != nil {
+			return v, fmt.Errorf("invalid Marathon version %q", version)
+		}
+	}
+	return v, nil
+}
+
+func (v marathonVersion) since(o marathonVersion) bool {
+	if v.major != o.major {
+		return v.major > o.major
+	}
+	if v.minor != o.minor {
+		return v.minor > o.minor
+	}
+	return v.patch >= o.patch
+}
+
+func (v marathonVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+// parser returns the parser of the detected Marathon version, or an error
+// if that version is too old to be scraped.
+func (e *Exporter) parser() (marathonParser, error) {
+	version, known := e.detectVersion()
+	if !known {
+		return fallbackParser, nil
+	}
+	for _, p := range marathonParsers {
+		if version.since(p.since) {
+			return p, nil
+		}
+	}
+	return marathonParser{}, &unsupportedVersionError{version}
+}
+
+// detectVersion returns the version of Marathon, querying v2/info at most
+// once every versionInterval. Invalid answers are cached too, so that an old
+// Marathon without a usable v2/info is not queried on every scrape. When
+// Marathon does not answer, as while it elects a leader, the last known
+// version is kept and v2/info is queried again after versionRetryInterval.
+func (e *Exporter) detectVersion() (marathonVersion, bool) {
+	e.versionMutex.Lock()
+	defer e.versionMutex.Unlock()
+	if time.Now().Before(e.versionNext) {
+		return e.version, e.versionKnown
+	}
+
+	ctx, cancel := context.WithTimeout(e.ctx, versionTimeout)
+	defer cancel()
+	content, err := e.scraper.Scrape(ctx, "v2/info")
+	if err != nil {
+		log.Warnf("Problem detecting Marathon version, retrying in %v: %v\n", versionRetryInterval, err)
+		e.versionNext = time.Now().Add(versionRetryInterval)
+		return e.version, e.versionKnown
+	}
+
+	version, leader, err := parseInfo(content)
+	switch {
+	case err != nil:
+		log.Warnf("Problem detecting Marathon version: %v\n", err)
+	case e.versionKnown && version == e.version:
+	case !supportedVersion(version):
+		log.Errorf("Detected Marathon version %v: %v\n", version, &unsupportedVersionError{version})
+	default:
+		log.Infof("Detected Marathon version %v\n", version)
+	}
+	e.version, e.versionKnown, e.versionNext = version, err == nil, time.Now().Add(e.versionInterval)
+	e.leader = leader
+
+	if e.versionKnown && !supportedVersion(version) {
+		e.unsupportedVersion.Set(1)
+	} else {
+		e.unsupportedVersion.Set(0)
+	}
+	return e.version, e.versionKnown
+}
+
+// parseInfo returns the version and the current leader of Marathon from its
+// v2/info response.
+func parseInfo(content []byte) (marathonVersion, string, error) {
+	json, err := gabs.ParseJSON(content)
+	if err != nil {
+		return marathonVersion{}, "", err
+	}
+
+	leader, _ := json.Path("leader").Data().(string)
+	data, ok := json.Path("version").Data().(string)
+	if !ok {
+		return marathonVersion{}, leader, errors.New("no version in v2/info response")
+	}
+	version, err := parseVersion(data)
+	return version, leader, err
+}
+
+// currentMetricNames reports whether the last detected Marathon version uses
+// the metric names introduced in Marathon 1.7.
+func (e *Exporter) currentMetricNames() bool {
+	e.versionMutex.Lock()
+	defer e.versionMutex.Unlock()
+	return e.versionKnown && e.version.since(marathonVersion{1, 7, 0})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_parse_version(t *testing.T) {
+	cases := []struct {
+		version string
+		expect  marathonVersion
+	}{
+		{version: "1.6.322", expect: marathonVersion{1, 6, 322}},
+		{version: "1.7.50-a4b5c6d", expect: marathonVersion{1, 7, 50}},
+		{version: "v1.8.194+dev", expect: marathonVersion{1, 8, 194}},
+		{version: "1.4", expect: marathonVersion{1, 4, 0}},
+	}
+
+	for _, c := range cases {
+		version, err := parseVersion(c.version)
+		if err != nil {
+			t.Errorf("unexpected error parsing %s: %v", c.version, err)
+		} else if version != c.expect {
+			t.Errorf("expected %s to parse as %v, got %v", c.version, c.expect, version)
+		}
+	}
+
+	for _, version := range []string{"", "1", "1.x.0", "1.2.3.4"} {
+		if _, err := parseVersion(version); err == nil {
+			t.Errorf("expected an error parsing %q", version)
+		}
+	}
+}
+
+func Test_version_since(t *testing.T) {
+	v := marathonVersion{1, 7, 0}
+	for _, o := range []marathonVersion{{1, 7, 0}, {1, 6, 322}, {0, 9, 9}} {
+		if !v.since(o) {
+			t.Errorf("expected %v to be %v or later", v, o)
+		}
+	}
+	for _, o := range []marathonVersion{{1, 7, 1}, {1, 8, 0}, {2, 0, 0}} {
+		if v.since(o) {
+			t.Errorf("expected %v to be older than %v", v, o)
+		}
+	}
+}
+
+func Test_unsupported_version(t *testing.T) {
+	results := scrapeWith(t, pathScraper{
+		"v2/apps?embed=apps.taskStats": `{"apps": [{"id": "/foo", "version": "1", "instances": 1}]}`,
+		"v2/info":                      `{"version": "1.4.0"}`,
+		"metrics":                      `{"counters": {"foo_count": {"count": 7}}}`,
+	}, metricsFormatAuto)
+
+	assertResultsContain(t, results,
+		"marathon_exporter_unsupported_marathon_version 1",
+		"marathon_exporter_last_scrape_error 1",
+		"marathon_up 0")
+	assertResultsDoNotContain(t, results,
+		"marathon_app_instances",
+		"marathon_foo_count")
+}
+
+func Test_supported_version(t *testing.T) {
+	results := scrapeWith(t, pathScraper{
+		"v2/apps?embed=apps.taskStats": `{"apps": [{"id": "/foo", "version": "1", "instances": 1}]}`,
+		"v2/info":                      `{"version": "1.5.0"}`,
+		"metrics":                      `{"counters": {"foo_count": {"count": 7}}}`,
+	}, metricsFormatAuto)
+
+	assertResultsContain(t, results,
+		"marathon_exporter_unsupported_marathon_version 0",
+		`marathon_app_instances\{app="/foo",app_version="1"\} 1`,
+		"marathon_foo_count 7",
+		"marathon_up 1")
+}
+
+// infoScraper answers v2/info with info, or fails with err.
+type infoScraper struct {
+	info     string
+	err      error
+	deadline time.Time
+}
+
+func (s *infoScraper) Scrape(ctx context.Context, path string) ([]byte, error) {
+	s.deadline, _ = ctx.Deadline()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []byte(s.info), nil
+}
+
+func Test_detect_version_keeps_last_known_version(t *testing.T) {
+	scraper := &infoScraper{info: `{"version": "1.7.50"}`}
+	exporter := NewExporter(scraper, "marathon")
+	exporter.versionInterval = time.Minute
+
+	if version, known := exporter.detectVersion(); !known || version != (marathonVersion{1, 7, 50}) {
+		t.Fatalf("expected version 1.7.50, got %v (known: %v)", version, known)
+	}
+	if scraper.deadline.IsZero() || scraper.deadline.After(time.Now().Add(versionTimeout)) {
+		t.Errorf("expected the detection to be bounded by %v", versionTimeout)
+	}
+
+	// Marathon electing a leader does not change the parser
+	scraper.err = &statusError{code: 503, status: "503 Service Unavailable"}
+	exporter.versionNext = time.Time{}
+	if version, known := exporter.detectVersion(); !known || version != (marathonVersion{1, 7, 50}) {
+		t.Errorf("expected version 1.7.50 to be kept, got %v (known: %v)", version, known)
+	}
+	if retry := time.Until(exporter.versionNext); retry > versionRetryInterval {
+		t.Errorf("expected the detection to be retried within %v, got %v", versionRetryInterval, retry)
+	}
+
+	// An answer without a usable version is trusted for the whole interval
+	scraper.info, scraper.err = `{"name": "marathon"}`, nil
+	exporter.versionNext = time.Time{}
+	if _, known := exporter.detectVersion(); known {
+		t.Errorf("expected the version to be unknown")
+	}
+	if retry := time.Until(exporter.versionNext); retry <= versionRetryInterval {
+		t.Errorf("expected the unknown version to be cached for the version interval, got %v", retry)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/common/log"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// webConfig secures the exporter's own web endpoint. It follows the web
+// configuration file format of the Prometheus exporter toolkit, and, as
+// there, the file is read again on every request and TLS handshake, its
+// certificates only on TLS handshakes.
+type webConfig struct {
+	TLSConfig  webTLSConfig      `yaml:"tls_server_config"`
+	HTTPConfig webHTTPConfig     `yaml:"http_server_config"`
+	Users      map[string]string `yaml:"basic_auth_users"`
+}
+
+type webTLSConfig struct {
+	CertFile                 string   `yaml:"cert_file"`
+	KeyFile                  string   `yaml:"key_file"`
+	ClientAuthType           string   `yaml:"client_auth_type"`
+	ClientCAFile             string   `yaml:"client_ca_file"`
+	ClientAllowedSANs        []string `yaml:"client_allowed_sans"`
+	CipherSuites             []string `yaml:"cipher_suites"`
+	CurvePreferences         []string `yaml:"curve_preferences"`
+	MinVersion               string   `yaml:"min_version"`
+	MaxVersion               string   `yaml:"max_version"`
+	PreferServerCipherSuites bool     `yaml:"prefer_server_cipher_suites"`
+}
+
+type webHTTPConfig struct {
+	HTTP2   bool              `yaml:"http2"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+var curves = map[string]tls.CurveID{
+	"CurveP256": tls.CurveP256,
+	"CurveP384": tls.CurveP384,
+	"CurveP521": tls.CurveP521,
+	"X25519":    tls.X25519,
+}
+
+// loadWebConfig reads the web configuration file at path, checking that its
+// TLS settings and certificates are usable.
+func loadWebConfig(path string) (*webConfig, error) {
+	config, err := parseWebConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := config.TLSConfig.tlsConfig(); err != nil {
+		return nil, fmt.Errorf("error in %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// parseWebConfig reads the web configuration file at path without loading
+// the certificates it refers to.
+func parseWebConfig(path string) (*webConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &webConfig{HTTPConfig: webHTTPConfig{HTTP2: true}}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return config, nil
+}
+
+func (c webTLSConfig) enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// tlsConfig returns the TLS configuration of the server, nil if TLS is
+// disabled.
+func (c webTLSConfig) tlsConfig() (*tls.Config, error) {
+	if !c.enabled() {
+		if c.ClientCAFile != "" || c.ClientAuthType != "" {
+			return nil, errors.New("client certificates require cert_file and key_file")
+		}
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("both cert_file and key_file are required")
+	}
+
+	config := &tls.Config{
+		MinVersion:               tls.VersionTLS12,
+		PreferServerCipherSuites: c.PreferServerCipherSuites,
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config.Certificates = []tls.Certificate{cert}
+
+	var ok bool
+	if config.ClientAuth, ok = clientAuthTypes[c.ClientAuthType]; !ok {
+		return nil, fmt.Errorf("unknown client_auth_type %q", c.ClientAuthType)
+	}
+	if c.ClientCAFile != "" {
+		if config.ClientAuth == tls.NoClientCert {
+			return nil, errors.New("client_ca_file requires a client_auth_type verifying client certificates")
+		}
+		ca, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", c.ClientCAFile)
+		}
+	}
+	if len(c.ClientAllowedSANs) > 0 {
+		if config.ClientAuth != tls.RequireAndVerifyClientCert {
+			return nil, errors.New("client_allowed_sans requires client_auth_type RequireAndVerifyClientCert")
+		}
+		config.VerifyPeerCertificate = c.verifySANs
+	}
+
+	if c.MinVersion != "" {
+		if config.MinVersion, ok = tlsVersions[c.MinVersion]; !ok {
+			return nil, fmt.Errorf("unknown min_version %q", c.MinVersion)
+		}
+	}
+	if c.MaxVersion != "" {
+		if config.MaxVersion, ok = tlsVersions[c.MaxVersion]; !ok {
+			return nil, fmt.Errorf("unknown max_version %q", c.MaxVersion)
+		}
+	}
+
+	for _, name := range c.CipherSuites {
+		id, ok := cipherSuite(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		config.CipherSuites = append(config.CipherSuites, id)
+	}
+	for _, name := range c.CurvePreferences {
+		id, ok := curves[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve %q", name)
+		}
+		config.CurvePreferences = append(config.CurvePreferences, id)
+	}
+	return config, nil
+}
+
+func cipherSuite(name string) (uint16, bool) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// verifySANs accepts the client certificates with one of the allowed
+// subject alternative names.
+func (c webTLSConfig) verifySANs(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	for _, allowed := range c.ClientAllowedSANs {
+		for _, san := range sans {
+			if san == allowed {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("client certificate has none of the allowed subject alternative names")
+}
+
+// webHandler checks the basic auth credentials of the requests and sets the
+// configured headers on their responses.
+type webHandler struct {
+	path    string
+	handler http.Handler
+
+	// authenticated caches the successful bcrypt comparisons, which are
+	// deliberately slow.
+	mutex         sync.Mutex
+	authenticated map[[sha256.Size]byte]bool
+}
+
+// dummyHash is compared to the passwords of unknown users, so that they take
+// as long to reject as wrong passwords.
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+func (h *webHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	config, err := parseWebConfig(h.path)
+	if err != nil {
+		log.Errorf("Unable to load web configuration: %v\n", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	for name, value := range config.HTTPConfig.Headers {
+		w.Header().Set(name, value)
+	}
+
+	if len(config.Users) > 0 {
+		user, password, ok := r.BasicAuth()
+		if !ok || !h.authenticate(config.Users, user, password) {
+			w.Header().Set("WWW-Authenticate", "Basic")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+func (h *webHandler) authenticate(users map[string]string, user, password string) bool {
+	hash, known := users[user]
+	if !known {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+		})
+		hash = string(dummyHash)
+	}
+
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + hash))
+	h.mutex.Lock()
+	cached := h.authenticated[key]
+	h.mutex.Unlock()
+	if cached {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil || !known {
+		return false
+	}
+	h.mutex.Lock()
+	h.authenticated[key] = true
+	h.mutex.Unlock()
+	return true
+}
+
+// serveWeb runs server on listener, with the TLS settings and basic auth
+// users of the web configuration file at path, if any.
+func serveWeb(server *http.Server, listener net.Listener, path string) error {
+	if path == "" {
+		return server.Serve(listener)
+	}
+
+	config, err := loadWebConfig(path)
+	if err != nil {
+		return err
+	}
+	server.Handler = &webHandler{
+		path:          path,
+		handler:       server.Handler,
+		authenticated: make(map[[sha256.Size]byte]bool),
+	}
+	if !config.TLSConfig.enabled() {
+		return server.Serve(listener)
+	}
+
+	server.TLSConfig = &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config, err := parseWebConfig(path)
+			if err == nil {
+				var tlsConfig *tls.Config
+				if tlsConfig, err = config.TLSConfig.tlsConfig(); err == nil {
+					return tlsConfig, nil
+				}
+			}
+			log.Errorf("Unable to load web configuration: %v\n", err)
+			return nil, err
+		},
+	}
+	if !config.HTTPConfig.HTTP2 {
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	log.Infoln("TLS is enabled on the web endpoint")
+	return server.ServeTLS(listener, "", "")
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newCert writes a certificate and its key signed by parent, or self-signed
+// if parent is nil, to dir.
+func newCert(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, name+".pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(t, dir, name+"-key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func Test_web_config_validation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	newCert(t, dir, "server", nil, nil)
+
+	cases := map[string]string{
+		"unknown field":      "tls_server_config:\n  certificate: foo\n",
+		"missing key":        "tls_server_config:\n  cert_file: " + dir + "/server.pem\n",
+		"client auth no tls": "tls_server_config:\n  client_auth_type: RequireAndVerifyClientCert\n",
+		"unknown auth type": "tls_server_config:\n  cert_file: " + dir + "/server.pem\n  key_file: " + dir + "/server-key.pem\n" +
+			"  client_auth_type: Always\n",
+		"ca without auth": "tls_server_config:\n  cert_file: " + dir + "/server.pem\n  key_file: " + dir + "/server-key.pem\n" +
+			"  client_ca_file: " + dir + "/server.pem\n",
+		"unknown version": "tls_server_config:\n  cert_file: " + dir + "/server.pem\n  key_file: " + dir + "/server-key.pem\n" +
+			"  min_version: SSL3\n",
+		"unknown cipher": "tls_server_config:\n  cert_file: " + dir + "/server.pem\n  key_file: " + dir + "/server-key.pem\n" +
+			"  cipher_suites: [TLS_FOO]\n",
+	}
+	for name, content := range cases {
+		if _, err := loadWebConfig(writeFile(t, dir, "web.yml", content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	config, err := loadWebConfig(writeFile(t, dir, "web.yml", "tls_server_config:\n"+
+		"  cert_file: "+dir+"/server.pem\n  key_file: "+dir+"/server-key.pem\n"+
+		"  min_version: TLS13\n  cipher_suites: [TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256]\n"+
+		"  curve_preferences: [X25519]\n"+
+		"http_server_config:\n  headers:\n    X-Frame-Options: deny\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.HTTPConfig.HTTP2 {
+		t.Errorf("expected HTTP/2 to be enabled by default")
+	}
+}
+
+func Test_web_basic_auth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeFile(t, dir, "web.yml", "basic_auth_users:\n  prometheus: "+string(hash)+"\n"+
+		"http_server_config:\n  headers:\n    X-Frame-Options: deny\n")
+
+	server := httptest.NewServer(&webHandler{
+		path:          path,
+		handler:       http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		authenticated: make(map[[32]byte]bool),
+	})
+	defer server.Close()
+
+	cases := []struct {
+		user, password string
+		status         int
+	}{
+		{status: http.StatusUnauthorized},
+		{user: "prometheus", password: "wrong", status: http.StatusUnauthorized},
+		{user: "other", password: "secret", status: http.StatusUnauthorized},
+		{user: "prometheus", password: "secret", status: http.StatusOK},
+		{user: "prometheus", password: "secret", status: http.StatusOK},
+	}
+	for _, c := range cases {
+		request, _ := http.NewRequest("GET", server.URL, nil)
+		if c.user != "" {
+			request.SetBasicAuth(c.user, c.password)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != c.status {
+			t.Errorf("%s:%s: expected status %d, got %d", c.user, c.password, c.status, response.StatusCode)
+		}
+		if h := response.Header.Get("X-Frame-Options"); h != "deny" {
+			t.Errorf("expected configured header, got %q", h)
+		}
+	}
+}
+
+func Test_web_requests_do_not_load_certificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newCert(t, dir, "server", nil, nil)
+	path := writeFile(t, dir, "web.yml", "tls_server_config:\n"+
+		"  cert_file: "+dir+"/server.pem\n  key_file: "+dir+"/server-key.pem\n")
+	handler := &webHandler{
+		path:          path,
+		handler:       http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		authenticated: make(map[[32]byte]bool),
+	}
+
+	// Certificates are only loaded on TLS handshakes
+	if err := os.Remove(dir + "/server-key.pem"); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/-/healthy", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func Test_web_client_certificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := newCert(t, dir, "ca", nil, nil)
+	newCert(t, dir, "server", ca, caKey)
+	newCert(t, dir, "client", ca, caKey)
+	path := writeFile(t, dir, "web.yml", "tls_server_config:\n"+
+		"  cert_file: "+dir+"/server.pem\n  key_file: "+dir+"/server-key.pem\n"+
+		"  client_auth_type: RequireAndVerifyClientCert\n  client_ca_file: "+dir+"/ca.pem\n")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go serveWeb(server, listener, path)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, err := tls.LoadX509KeyPair(dir+"/client.pem", dir+"/client-key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(certs ...tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+		response, err := client.Get("https://" + listener.Addr().String())
+		if err == nil {
+			response.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(); err == nil {
+		t.Errorf("expected a client without certificate to be rejected")
+	}
+	if err := get(clientCert); err != nil {
+		t.Errorf("expected a client with a certificate to be accepted, got %v", err)
+	}
+}